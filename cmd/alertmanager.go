@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/NETWAYS/go-check"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/NETWAYS/notify_zammad/internal/alertmanager"
+	"github.com/NETWAYS/notify_zammad/internal/notifier"
+	"github.com/NETWAYS/notify_zammad/internal/routing"
+)
+
+// AlertmanagerConfig holds the mapping of Alertmanager alerts to notifications
+type AlertmanagerConfig struct {
+	HostLabel          string
+	ServiceLabel       string
+	StateLabel         string
+	ZammadGroup        string
+	ZammadCustomer     string
+	ZammadOrganization string
+	// Rules route the alerts by their labels, which are matched as custom variables
+	Rules *routing.Rules
+}
+
+var alertmanagerConfig AlertmanagerConfig
+
+var alertmanagerCmd = &cobra.Command{
+	Use:   "alertmanager",
+	Short: "Handle a Prometheus Alertmanager webhook message read from stdin",
+	Long: `Handle a Prometheus Alertmanager webhook message read from stdin
+
+Firing alerts are handled as Problem notifications, resolved alerts as Recovery notifications.
+The alert labels are mapped onto the host and service used to track the tickets.`,
+	Run: runAlertmanager,
+}
+
+func init() {
+	rootCmd.AddCommand(alertmanagerCmd)
+
+	fs := alertmanagerCmd.Flags()
+	addAlertmanagerFlags(fs)
+
+	fs.SortFlags = false
+}
+
+// addAlertmanagerFlags adds the flags for the mapping of alerts to the given FlagSet
+func addAlertmanagerFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&alertmanagerConfig.HostLabel, "host-label", "instance",
+		"Alert label used as host name")
+	fs.StringVar(&alertmanagerConfig.ServiceLabel, "service-label", "alertname",
+		"Alert label used as service name")
+	fs.StringVar(&alertmanagerConfig.StateLabel, "state-label", "severity",
+		"Alert label used as check state of firing alerts")
+	fs.StringVar(&alertmanagerConfig.ZammadGroup, "zammad-group", "",
+		"Custom Zammad Field for the group of alert tickets, required unless set by the routing rules")
+	fs.StringVar(&alertmanagerConfig.ZammadCustomer, "zammad-customer", "",
+		"Custom Zammad Field for the customer of alert tickets, required unless set by the routing rules")
+	fs.StringVar(&alertmanagerConfig.ZammadOrganization, "zammad-organization", "",
+		"Zammad organization of alert tickets, by default the customer's organization is used")
+}
+
+// runAlertmanager is the cobra.Command that is executed for the alertmanager subcommand
+func runAlertmanager(_ *cobra.Command, _ []string) {
+	m, err := alertmanager.Decode(os.Stdin)
+
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	alertmanagerConfig.Rules = cliConfig.Rules()
+
+	// Alerts have no Icinga object that could be acknowledged,
+	// the acknowledgement flag is not available for this command
+	nt := cliConfig.NewNotifier()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(Timeout)*time.Second)
+	defer cancel()
+
+	errs := make([]error, 0)
+
+	// Each alert is handled on its own, a failing alert does not stop the others
+	for _, a := range m.Alerts {
+		n, err := alertToNotification(a, alertmanagerConfig)
+
+		if err == nil {
+			_, err = nt.Process(ctx, n)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alert %s: %w", a.Fingerprint, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		check.ExitError(errors.Join(errs...))
+	}
+
+	check.ExitRaw(check.OK, fmt.Sprintf("%d alerts processed", len(m.Alerts))+cliConfig.usedEndpoint())
+}
+
+// alertToNotification maps an Alertmanager alert onto a notification.
+// Firing alerts become Problem notifications, resolved alerts Recovery notifications.
+func alertToNotification(a alertmanager.Alert, cfg AlertmanagerConfig) (notifier.Notification, error) {
+	n := notifier.Notification{
+		ZammadGroup:        cfg.ZammadGroup,
+		ZammadCustomer:     cfg.ZammadCustomer,
+		ZammadOrganization: cfg.ZammadOrganization,
+		IcingaHostname:     a.Labels[cfg.HostLabel],
+		IcingaServiceName:  a.Labels[cfg.ServiceLabel],
+		IcingaVars:         a.Labels,
+		Details:            make(map[string]string, len(a.Annotations)+2),
+	}
+
+	if n.IcingaHostname == "" {
+		return n, fmt.Errorf("alert has no label '%s' to use as host name", cfg.HostLabel)
+	}
+
+	if a.Firing() {
+		n.IcingaNotificationType = "Problem"
+		n.IcingaCheckState = a.Labels[cfg.StateLabel]
+		n.IcingaDate = a.StartsAt.Format(time.RFC3339)
+
+		if n.IcingaCheckState == "" {
+			n.IcingaCheckState = "firing"
+		}
+	} else {
+		n.IcingaNotificationType = "Recovery"
+		n.IcingaCheckState = "resolved"
+		n.IcingaDate = a.EndsAt.Format(time.RFC3339)
+		n.IcingaProblemStart = a.StartsAt.Format(time.RFC3339)
+	}
+
+	// Prefer the commonly used annotations for the check output
+	switch {
+	case a.Annotations["summary"] != "":
+		n.IcingaCheckOutput = a.Annotations["summary"]
+	case a.Annotations["description"] != "":
+		n.IcingaCheckOutput = a.Annotations["description"]
+	default:
+		n.IcingaCheckOutput = a.Labels["alertname"]
+	}
+
+	for k, v := range a.Annotations {
+		n.Details[k] = v
+	}
+
+	if a.GeneratorURL != "" {
+		n.Details["Source"] = a.GeneratorURL
+	}
+
+	if a.Fingerprint != "" {
+		n.Details["Fingerprint"] = a.Fingerprint
+	}
+
+	n.Route(cfg.Rules)
+
+	return n, n.Validate()
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NETWAYS/notify_zammad/internal/alertmanager"
+	"github.com/NETWAYS/notify_zammad/internal/notifier"
+	"github.com/NETWAYS/notify_zammad/internal/routing"
+)
+
+func TestAlertToNotification(t *testing.T) {
+	cfg := AlertmanagerConfig{
+		HostLabel:      "instance",
+		ServiceLabel:   "alertname",
+		StateLabel:     "severity",
+		ZammadGroup:    "Users",
+		ZammadCustomer: "jon.snow@zammad",
+	}
+
+	a := alertmanager.Alert{
+		Status:      alertmanager.StatusFiring,
+		Labels:      map[string]string{"alertname": "InstanceDown", "instance": "host01:9100", "severity": "critical"},
+		Annotations: map[string]string{"summary": "Instance host01:9100 down", "runbook_url": "https://wiki.example/InstanceDown"},
+		StartsAt:    time.Date(2025, 5, 5, 9, 38, 25, 0, time.UTC),
+		Fingerprint: "c5a5b3a3b9e6f0d1",
+	}
+
+	n, err := alertToNotification(a, cfg)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if n.IcingaNotificationType != "Problem" || n.IcingaCheckState != "critical" {
+		t.Errorf("Expected critical Problem notification got: %v", n)
+	}
+
+	if n.IcingaHostname != "host01:9100" || n.IcingaServiceName != "InstanceDown" {
+		t.Errorf("Expected host and service from labels got: %v", n)
+	}
+
+	body := notifier.CreateArticleBody(n, "Problem")
+
+	for _, expected := range []string{"<p>Check Output: Instance host01:9100 down</p>", "<p>runbook_url: https://wiki.example/InstanceDown</p>", "<p>Fingerprint: c5a5b3a3b9e6f0d1</p>"} {
+		if !strings.Contains(body, expected) {
+			t.Error("\nActual: ", body, "\nExpected: ", expected)
+		}
+	}
+
+	a.Status = alertmanager.StatusResolved
+
+	n, err = alertToNotification(a, cfg)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if n.IcingaNotificationType != "Recovery" {
+		t.Errorf("Expected Recovery notification got: %v", n)
+	}
+
+	delete(a.Labels, "instance")
+
+	_, err = alertToNotification(a, cfg)
+
+	if err == nil || !strings.Contains(err.Error(), "no label 'instance'") {
+		t.Errorf("Expected missing label error got: %v", err)
+	}
+}
+
+func TestAlertToNotification_Route(t *testing.T) {
+	rules, err := routing.Parse(strings.NewReader(`{
+  "rules": [{"match": {"vars": {"team": "storage"}}, "group": "Storage"}],
+  "default": {"group": "Users", "customer": "monitoring@zammad"}
+}`))
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	cfg := AlertmanagerConfig{
+		HostLabel:    "instance",
+		ServiceLabel: "alertname",
+		StateLabel:   "severity",
+		Rules:        rules,
+	}
+
+	a := alertmanager.Alert{
+		Status: alertmanager.StatusFiring,
+		Labels: map[string]string{"alertname": "DiskFull", "instance": "nas01", "team": "storage"},
+	}
+
+	n, err := alertToNotification(a, cfg)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	// The labels are matched as custom variables
+	if n.ZammadGroup != "Storage" || n.ZammadCustomer != "monitoring@zammad" {
+		t.Errorf("Expected alert routed by labels got: %v", n)
+	}
+}
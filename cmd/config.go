@@ -1,17 +1,28 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	checkhttpconfig "github.com/NETWAYS/go-check-network/http/config"
+	"github.com/NETWAYS/go-icingadsl"
+	"github.com/NETWAYS/notify_zammad/internal/assign"
 	"github.com/NETWAYS/notify_zammad/internal/client"
+	"github.com/NETWAYS/notify_zammad/internal/icinga"
+	"github.com/NETWAYS/notify_zammad/internal/notifier"
+	"github.com/NETWAYS/notify_zammad/internal/resolve"
+	"github.com/NETWAYS/notify_zammad/internal/routing"
 )
 
 type Config struct {
@@ -21,22 +32,97 @@ type Config struct {
 	CertFile  string `env:"NOTIFY_ZAMMAD_CERT_FILE"`
 	KeyFile   string `env:"NOTIFY_ZAMMAD_KEY_FILE"`
 	Hostname  string `env:"NOTIFY_ZAMMAD_HOSTNAME"`
+	// URLs are the base URLs of Zammad instances, which replace Hostname, Port and Secure.
+	// The first instance is used, the others if it fails.
+	URLs []string
 
-	ZammadGroup            string
-	ZammadCustomer         string
-	IcingaHostname         string
-	IcingaServiceName      string
-	IcingaCheckState       string
-	IcingaCheckOutput      string
-	IcingaNotificationType string
-	IcingaAuthor           string
-	IcingaComment          string
-	IcingaDate             string
-
-	Port int
-
-	Insecure bool
-	Secure   bool
+	// OnBehalfOf is the Zammad user all requests are performed as
+	OnBehalfOf       string
+	OnBehalfOfAuthor bool
+
+	// BearerToken is sent as Authorization: Bearer, e.g. for an API gateway
+	BearerToken string
+
+	OAuth2TokenURL     string
+	OAuth2ClientID     string
+	OAuth2ClientSecret string
+	OAuth2Scopes       []string
+
+	TLSMinVersion string
+	TLSServerName string
+	TLSPins       []string
+
+	IcingaAPIBasicAuth string `env:"NOTIFY_ZAMMAD_ICINGA_BASICAUTH"`
+	IcingaAPICAFile    string `env:"NOTIFY_ZAMMAD_ICINGA_CA_FILE"`
+	IcingaAPICertFile  string `env:"NOTIFY_ZAMMAD_ICINGA_CERT_FILE"`
+	IcingaAPIKeyFile   string `env:"NOTIFY_ZAMMAD_ICINGA_KEY_FILE"`
+	IcingaAPIHostname  string `env:"NOTIFY_ZAMMAD_ICINGA_HOSTNAME"`
+	IcingaAPIAuthor    string
+
+	notifier.Notification
+
+	// Input is the source of the notification data
+	Input string
+	// InputDialect is the monitoring system the notification data originates from
+	InputDialect string
+
+	// LogFile is written instead of stderr if set
+	LogFile string
+	// LogFormat is either text or json
+	LogFormat string
+	// RoutingFile holds the rules choosing the Zammad fields of new tickets
+	RoutingFile string
+	// CacheFile stores the resolved Zammad IDs, defaults to the user's cache directory
+	CacheFile string
+	// CacheTTL is the time the resolved IDs are cached, 0 disables the cache
+	CacheTTL time.Duration
+
+	Port          int
+	IcingaAPIPort int
+
+	Insecure          bool
+	Secure            bool
+	IcingaAPIInsecure bool
+	IcingaAcknowledge bool
+	DryRun            bool
+	Debug             bool
+
+	// Lookup resolves group and customer before tickets are created
+	Lookup bool
+	// CreateCustomer creates customers referenced by email that don't exist yet
+	CreateCustomer bool
+
+	// AssignStrategy picks the owner of new tickets from the AssignAgents
+	AssignStrategy string
+	AssignAgents   []string
+	// AssignAuthor reassigns tickets to the author of Acknowledgement notifications
+	AssignAuthor bool
+
+	// UpdateAttributes sets the attributes on existing tickets as well
+	UpdateAttributes bool
+	// VarAttributes and VarDetails map custom variables to ticket attributes and article lines
+	VarAttributes map[string]string
+	VarDetails    map[string]string
+
+	// LinkHostTickets links service tickets to the ticket of their host
+	LinkHostTickets bool
+	// MergeServiceProblems adds service notifications to the ticket of their host
+	MergeServiceProblems bool
+
+	// ArticleTypes, ArticleSenders, ArticlePublic and ArticleTo configure the articles by notification type
+	ArticleTypes   map[string]string
+	ArticleSenders map[string]string
+	ArticlePublic  []string
+	ArticleTo      map[string]string
+
+	// TimeAccounting accounts the time of Recovery and Acknowledgement articles
+	TimeAccounting bool
+	TimeRounding   time.Duration
+	TimeFactor     float64
+
+	logger   *slog.Logger
+	failover *client.FailoverRoundTripper
+	rules    *routing.Rules
 }
 
 var cliConfig Config
@@ -63,36 +149,16 @@ along with this program. If not, see https://www.gnu.org/licenses/.
 `
 
 func (c *Config) NewClient() *client.Client {
-	u := url.URL{
-		Scheme: "http",
-		Host:   c.Hostname + ":" + strconv.Itoa(c.Port),
-	}
+	endpoints := c.endpoints()
 
-	if c.Secure {
-		u.Scheme = "https"
-	}
-
-	// Create TLS configuration for default RoundTripper
-	tlsConfig, err := checkhttpconfig.NewTLSConfig(&checkhttpconfig.TLSConfig{
-		InsecureSkipVerify: c.Insecure,
-		CAFile:             c.CAFile,
-		KeyFile:            c.KeyFile,
-		CertFile:           c.CertFile,
-	})
+	u := endpoints[0].URL
 
-	if err != nil {
-		fmt.Println("error creating TLS configuration", err)
-		os.Exit(1)
-	}
+	var rt http.RoundTripper = endpoints[0].Transport
 
-	var rt http.RoundTripper = &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&net.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSHandshakeTimeout: 10 * time.Second,
-		TLSClientConfig:     tlsConfig,
+	// Use the other instances if the first one fails
+	if len(endpoints) > 1 {
+		c.failover = client.NewFailoverRoundTripper(endpoints, c.Logger())
+		rt = c.failover
 	}
 
 	// Using a Bearer Token for authentication
@@ -104,7 +170,7 @@ func (c *Config) NewClient() *client.Client {
 	if c.BasicAuth != "" {
 		s := strings.Split(c.BasicAuth, ":")
 		if len(s) != 2 {
-			fmt.Println("specify the user name and password for server authentication <user:password>", err)
+			fmt.Println("specify the user name and password for server authentication <user:password>")
 			os.Exit(1)
 		}
 
@@ -115,5 +181,453 @@ func (c *Config) NewClient() *client.Client {
 		rt = checkhttpconfig.NewBasicAuthRoundTripper(u, p, rt)
 	}
 
-	return client.NewClient(u, rt)
+	// Using a static Bearer Token for authentication
+	if c.BearerToken != "" {
+		rt = checkhttpconfig.NewAuthorizationCredentialsRoundTripper("Bearer", c.BearerToken, rt)
+	}
+
+	// Using an access token obtained via the OAuth2 client credentials flow.
+	// The token endpoint usually is a different server, thus the certificate pins
+	// and the unix socket of the Zammad connection are not used for it.
+	if c.OAuth2TokenURL != "" {
+		tokenRT := newTransport(&checkhttpconfig.TLSConfig{
+			InsecureSkipVerify: c.Insecure,
+			CAFile:             c.CAFile,
+		}, client.TLSOptions{})
+
+		rt = client.NewOAuth2RoundTripper(client.ClientCredentials{
+			TokenURL:     c.OAuth2TokenURL,
+			ClientID:     c.OAuth2ClientID,
+			ClientSecret: c.OAuth2ClientSecret,
+			Scopes:       c.OAuth2Scopes,
+		}, tokenRT, rt)
+	}
+
+	// Print changes instead of sending them
+	if c.DryRun {
+		rt = client.NewDryRunRoundTripper(os.Stdout, rt)
+	}
+
+	// Log all requests, the logging is the outermost wrapper, thus the headers
+	// added by the authentication are not seen and the OAuth2 token requests are not logged
+	if c.Debug {
+		rt = client.NewLoggingRoundTripper(c.Logger(), rt)
+	}
+
+	cl := client.NewClient(u, rt)
+
+	if c.OnBehalfOf != "" {
+		cl.Headers.Set(client.OnBehalfOfHeader, c.OnBehalfOf)
+	}
+
+	return cl
+}
+
+// NewNotifier creates a notifier using the Zammad client,
+// problems are acknowledged via the Icinga 2 API if enabled
+func (c *Config) NewNotifier() *notifier.Notifier {
+	for _, attr := range c.VarAttributes {
+		if slices.Contains(notifier.ReservedAttributes, attr) {
+			fmt.Printf("attribute %s is reserved and cannot be set by --var-attribute\n", attr)
+			os.Exit(1)
+		}
+	}
+
+	cl := c.NewClient()
+
+	nt := notifier.New(cl)
+	nt.Logger = c.Logger()
+	nt.OnBehalfOfAuthor = c.OnBehalfOfAuthor
+	nt.AssignAuthor = c.AssignAuthor
+	nt.UpdateAttributes = c.UpdateAttributes
+	nt.VarAttributes = c.VarAttributes
+	nt.VarDetails = c.VarDetails
+	nt.LinkHostTickets = c.LinkHostTickets
+	nt.MergeServiceProblems = c.MergeServiceProblems
+	nt.Articles = c.articleOptions()
+
+	if c.TimeAccounting {
+		nt.TimeAccounting = &notifier.TimeAccounting{
+			Rounding: c.TimeRounding,
+			Factor:   c.TimeFactor,
+			Logger:   c.Logger(),
+		}
+	}
+
+	r := c.NewResolver(cl)
+
+	if c.Lookup {
+		nt.Resolver = r
+	}
+
+	// Only looked up for email articles without --article-to
+	nt.Recipients = r
+
+	// Zammad rejects requests on behalf of unknown users, thus the author is looked up
+	if c.OnBehalfOfAuthor {
+		nt.Users = r
+	}
+
+	if c.AssignStrategy != "" {
+		nt.Assigner = c.NewAssigner(cl, r)
+	}
+
+	if c.IcingaAcknowledge {
+		nt.Acknowledger = c.NewIcingaClient()
+		nt.AcknowledgeAuthor = c.IcingaAPIAuthor
+	}
+
+	return nt
+}
+
+// NewResolver creates a resolver for the group and customer references,
+// if the lookup is enabled the resolved IDs are cached per Zammad instance
+func (c *Config) NewResolver(cl *client.Client) *resolve.Resolver {
+	r := resolve.New(cl)
+	r.CreateCustomers = c.CreateCustomer
+	r.Logger = c.Logger()
+
+	if !c.Lookup || c.CacheTTL <= 0 {
+		return r
+	}
+
+	path := c.CacheFile
+
+	if path == "" {
+		path = cachePath("ids.json")
+	}
+
+	// Without a home directory the IDs are looked up each time
+	if path != "" {
+		r.Cache = resolve.NewCache(path, c.CacheTTL, cl.URL.Redacted())
+	}
+
+	return r
+}
+
+// NewAssigner creates the strategy picking the owner of new tickets
+func (c *Config) NewAssigner(cl *client.Client, r *resolve.Resolver) notifier.Assigner {
+	switch c.AssignStrategy {
+	case assign.RoundRobinStrategy:
+		stateFile := cachePath("round-robin.json")
+
+		if c.CacheFile != "" {
+			stateFile = filepath.Join(filepath.Dir(c.CacheFile), "round-robin.json")
+		}
+
+		return &assign.RoundRobin{Agents: c.AssignAgents, StateFile: stateFile, DryRun: c.DryRun}
+	case assign.LeastOpenStrategy:
+		return &assign.LeastOpen{Agents: c.AssignAgents, Users: r, Searcher: cl}
+	default:
+		fmt.Printf("unsupported assignment strategy '%s'. Currently supported: %s/%s\n",
+			c.AssignStrategy, assign.RoundRobinStrategy, assign.LeastOpenStrategy)
+		os.Exit(1)
+	}
+
+	return nil
+}
+
+// cachePath returns the path of the given file in the user's cache directory,
+// empty if there is none
+func cachePath(name string) string {
+	dir, err := os.UserCacheDir()
+
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "notify_zammad", name)
+}
+
+// NewIcingaClient creates a client for the Icinga 2 API,
+// which is always served via HTTPS
+func (c *Config) NewIcingaClient() *icinga.Client {
+	u := url.URL{
+		Scheme: "https",
+		Host:   c.IcingaAPIHostname + ":" + strconv.Itoa(c.IcingaAPIPort),
+	}
+
+	t := newTransport(&checkhttpconfig.TLSConfig{
+		InsecureSkipVerify: c.IcingaAPIInsecure,
+		CAFile:             c.IcingaAPICAFile,
+		KeyFile:            c.IcingaAPIKeyFile,
+		CertFile:           c.IcingaAPICertFile,
+	}, client.TLSOptions{})
+
+	var rt http.RoundTripper = t
+
+	// The Icinga 2 API uses BasicAuth for authentication
+	if c.IcingaAPIBasicAuth != "" {
+		s := strings.SplitN(c.IcingaAPIBasicAuth, ":", 2)
+		if len(s) != 2 {
+			fmt.Println("specify the user name and password for the Icinga 2 API <user:password>")
+			os.Exit(1)
+		}
+
+		rt = checkhttpconfig.NewBasicAuthRoundTripper(s[0], s[1], rt)
+	}
+
+	// Print changes instead of sending them
+	if c.DryRun {
+		rt = client.NewDryRunRoundTripper(os.Stdout, rt)
+	}
+
+	if c.Debug {
+		rt = client.NewLoggingRoundTripper(c.Logger(), rt)
+	}
+
+	return icinga.NewClient(u, rt)
+}
+
+// Logger returns the logger configured by the log flags.
+// The logs are written to stderr or the log file, never to stdout
+// since the plugin output is read from there.
+func (c *Config) Logger() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+
+	var w io.Writer = os.Stderr
+
+	if c.LogFile != "" {
+		f, err := os.OpenFile(c.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+
+		if err != nil {
+			fmt.Println("could not open log file", err)
+			os.Exit(1)
+		}
+
+		w = f
+	}
+
+	opts := &slog.HandlerOptions{Level: slog.LevelInfo}
+
+	if c.Debug {
+		opts.Level = slog.LevelDebug
+	}
+
+	switch c.LogFormat {
+	case "json":
+		c.logger = slog.New(slog.NewJSONHandler(w, opts))
+	case "text", "":
+		c.logger = slog.New(slog.NewTextHandler(w, opts))
+	default:
+		fmt.Printf("unsupported log format '%s'. Currently supported: text/json\n", c.LogFormat)
+		os.Exit(1)
+	}
+
+	return c.logger
+}
+
+// articleOptions returns the ArticleOptions by notification type,
+// the options not set for a type are taken from the defaults
+func (c *Config) articleOptions() map[string]notifier.ArticleOptions {
+	options := make(map[string]notifier.ArticleOptions)
+
+	set := func(flag, notificationType string, update func(o *notifier.ArticleOptions)) {
+		t, err := icingadsl.ParseNotificationType(notificationType)
+
+		if err != nil {
+			fmt.Printf("unsupported notification type '%s' in --%s\n", notificationType, flag)
+			os.Exit(1)
+		}
+
+		key, _ := icingadsl.FormatNotificationType(t)
+
+		o, ok := options[key]
+
+		if !ok {
+			o = notifier.DefaultArticleOptions
+		}
+
+		update(&o)
+		options[key] = o
+	}
+
+	for t, articleType := range c.ArticleTypes {
+		set("article-type", t, func(o *notifier.ArticleOptions) { o.Type = articleType })
+	}
+
+	for t, sender := range c.ArticleSenders {
+		set("article-sender", t, func(o *notifier.ArticleOptions) { o.Sender = sender })
+	}
+
+	for _, t := range c.ArticlePublic {
+		set("article-public", t, func(o *notifier.ArticleOptions) { o.Internal = false })
+	}
+
+	for t, to := range c.ArticleTo {
+		set("article-to", t, func(o *notifier.ArticleOptions) { o.To = to })
+	}
+
+	return options
+}
+
+// Rules returns the routing rules read from the routing file,
+// nil if no routing file is configured
+func (c *Config) Rules() *routing.Rules {
+	if c.rules != nil || c.RoutingFile == "" {
+		return c.rules
+	}
+
+	rules, err := routing.Load(c.RoutingFile)
+
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	c.rules = rules
+
+	return c.rules
+}
+
+// endpoints returns the configured Zammad instances with their transports
+func (c *Config) endpoints() []client.Endpoint {
+	urls := make([]url.URL, 0, len(c.URLs))
+	sockets := make([]string, 0, len(c.URLs))
+
+	for _, raw := range c.URLs {
+		u, socket, err := parseBaseURL(raw)
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		urls = append(urls, u)
+		sockets = append(sockets, socket)
+	}
+
+	// Fall back to the deprecated host, port and secure flags
+	if len(urls) == 0 {
+		u := url.URL{
+			Scheme: "http",
+			Host:   c.Hostname + ":" + strconv.Itoa(c.Port),
+		}
+
+		if c.Secure {
+			u.Scheme = "https"
+		}
+
+		urls = append(urls, u)
+		sockets = append(sockets, "")
+	}
+
+	endpoints := make([]client.Endpoint, 0, len(urls))
+
+	for i, u := range urls {
+		t := newTransport(&checkhttpconfig.TLSConfig{
+			InsecureSkipVerify: c.Insecure,
+			CAFile:             c.CAFile,
+			KeyFile:            c.KeyFile,
+			CertFile:           c.CertFile,
+			ServerName:         c.TLSServerName,
+		}, c.tlsOptions())
+
+		// All connections are made to the socket, regardless of the URL's host
+		if socket := sockets[i]; socket != "" {
+			dialer := &net.Dialer{Timeout: 30 * time.Second}
+
+			t.Proxy = nil
+			t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			}
+		}
+
+		endpoints = append(endpoints, client.Endpoint{URL: u, Transport: t})
+	}
+
+	return endpoints
+}
+
+// usedEndpoint returns a note on the Zammad instance used for the plugin output,
+// which is only relevant if multiple instances are configured
+func (c *Config) usedEndpoint() string {
+	if c.failover == nil {
+		return ""
+	}
+
+	u := c.failover.Endpoint()
+
+	return " (via " + u.Redacted() + ")"
+}
+
+// parseBaseURL parses the base URL of Zammad, which may include a path prefix.
+// For unix:///path/to/socket URLs the path of the socket is returned
+// and requests are sent via HTTP to the socket.
+func parseBaseURL(raw string) (url.URL, string, error) {
+	u, err := url.Parse(raw)
+
+	if err != nil {
+		return url.URL{}, "", fmt.Errorf("invalid Zammad URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		if u.Host == "" {
+			return url.URL{}, "", fmt.Errorf("invalid Zammad URL '%s': missing host", raw)
+		}
+
+		return *u, "", nil
+	case "unix":
+		if u.Path == "" {
+			return url.URL{}, "", fmt.Errorf("invalid Zammad URL '%s': missing socket path", raw)
+		}
+
+		return url.URL{Scheme: "http", Host: "localhost"}, u.Path, nil
+	default:
+		return url.URL{}, "", fmt.Errorf("unsupported scheme in Zammad URL '%s'. Currently supported: http/https/unix", raw)
+	}
+}
+
+// tlsOptions returns the additional TLS options for the Zammad connection
+func (c *Config) tlsOptions() client.TLSOptions {
+	var opts client.TLSOptions
+
+	if c.TLSMinVersion != "" {
+		v, err := client.ParseTLSVersion(c.TLSMinVersion)
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		opts.MinVersion = v
+	}
+
+	for _, p := range c.TLSPins {
+		pin, err := client.ParsePin(p)
+
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		opts.Pins = append(opts.Pins, pin)
+	}
+
+	return opts
+}
+
+// newTransport creates the default transport with the given TLS configuration
+func newTransport(cfg *checkhttpconfig.TLSConfig, opts client.TLSOptions) *http.Transport {
+	tlsConfig, err := checkhttpconfig.NewTLSConfig(cfg)
+
+	if err != nil {
+		fmt.Println("error creating TLS configuration", err)
+		os.Exit(1)
+	}
+
+	opts.Apply(tlsConfig)
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+	}
 }
@@ -1,8 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"path/filepath"
+	"strconv"
 	"testing"
+
+	"github.com/NETWAYS/go-check"
 )
 
 func TestConfig(t *testing.T) {
@@ -17,3 +25,162 @@ func TestConfig(t *testing.T) {
 		t.Error("\nActual: ", c.URL.String(), "\nExpected: ", expected.String())
 	}
 }
+
+func TestConfig_IcingaEnv(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "root" || pass != "icinga" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`{"results":[{"attrs":{"state":0.0}}]}`))
+	}))
+
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+
+	t.Setenv("NOTIFY_ZAMMAD_ICINGA_HOSTNAME", u.Hostname())
+	t.Setenv("NOTIFY_ZAMMAD_ICINGA_BASICAUTH", "root:icinga")
+
+	port, _ := strconv.Atoi(u.Port())
+
+	c := Config{IcingaAPIHostname: "localhost", IcingaAPIPort: port, IcingaAPIInsecure: true}
+
+	check.LoadFromEnv(&c)
+
+	_, err := c.NewIcingaClient().GetObjectState(context.Background(), "MyHost", "")
+
+	if err != nil {
+		t.Errorf("Expected credentials from the environment got: %v", err)
+	}
+}
+
+func TestParseBaseURL(t *testing.T) {
+	tests := map[string]struct {
+		url      string
+		expected string
+		socket   string
+		err      bool
+	}{
+		"prefix":    {url: "https://intranet.example/zammad/", expected: "https://intranet.example/zammad/"},
+		"port":      {url: "http://zammad.example:8080", expected: "http://zammad.example:8080"},
+		"socket":    {url: "unix:///run/zammad.sock", expected: "http://localhost", socket: "/run/zammad.sock"},
+		"no-host":   {url: "https:///zammad", err: true},
+		"no-socket": {url: "unix://", err: true},
+		"scheme":    {url: "ftp://zammad.example", err: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			u, socket, err := parseBaseURL(test.url)
+
+			if test.err {
+				if err == nil {
+					t.Errorf("Expected error for %s", test.url)
+				}
+
+				return
+			}
+
+			if err != nil || u.String() != test.expected || socket != test.socket {
+				t.Error("\nActual: ", u.String(), socket, err, "\nExpected: ", test.expected, test.socket)
+			}
+		})
+	}
+}
+
+func TestConfig_URL(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zammad/api/v1/tickets/search" {
+			t.Errorf("Expected path prefix got: %s", r.URL.Path)
+		}
+
+		w.Write([]byte(`[]`))
+	}))
+
+	defer ts.Close()
+
+	c := (&Config{URLs: []string{ts.URL + "/zammad/"}}).NewClient()
+
+	_, err := c.SearchTickets(context.Background(), "MyHost", "")
+
+	if err != nil {
+		t.Errorf("Did not except error: %v", err)
+	}
+}
+
+func TestConfig_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "zammad.sock")
+
+	l, err := net.Listen("unix", socket)
+
+	if err != nil {
+		t.Skipf("unix sockets not supported: %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+
+	ts.Listener = l
+	ts.Start()
+
+	defer ts.Close()
+
+	c := (&Config{URLs: []string{"unix://" + socket}}).NewClient()
+
+	_, err = c.SearchTickets(context.Background(), "MyHost", "")
+
+	if err != nil {
+		t.Errorf("Did not except error: %v", err)
+	}
+}
+
+func TestConfig_Failover(t *testing.T) {
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+
+	defer standby.Close()
+
+	cfg := &Config{URLs: []string{primary.URL, standby.URL}}
+	c := cfg.NewClient()
+
+	_, err := c.SearchTickets(context.Background(), "MyHost", "")
+
+	if err != nil {
+		t.Errorf("Did not except error: %v", err)
+	}
+
+	if cfg.usedEndpoint() != " (via "+standby.URL+")" {
+		t.Errorf("Expected standby endpoint got: %s", cfg.usedEndpoint())
+	}
+}
+
+func TestConfig_ArticleOptions(t *testing.T) {
+	c := Config{
+		ArticleTypes:  map[string]string{"Recovery": "email", "acknowledgement": "note"},
+		ArticlePublic: []string{"recovery"},
+		ArticleTo:     map[string]string{"recovery": "ops@example.com"},
+	}
+
+	options := c.articleOptions()
+
+	recovery := options["recovery"]
+
+	if recovery.Type != "email" || recovery.Internal || recovery.To != "ops@example.com" || recovery.Sender != "Agent" {
+		t.Errorf("Unexpected recovery options: %v", recovery)
+	}
+
+	if ack := options["acknowledgement"]; ack.Type != "note" || !ack.Internal {
+		t.Errorf("Unexpected acknowledgement options: %v", ack)
+	}
+
+	if _, ok := options["problem"]; ok || len(options) != 2 {
+		t.Errorf("Expected only the configured types got: %v", options)
+	}
+}
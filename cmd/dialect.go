@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NETWAYS/go-icingadsl"
+)
+
+const (
+	dialectIcinga2 = "icinga2"
+	dialectNaemon  = "naemon"
+)
+
+// naemonNotificationTypes maps the Naemon/Nagios notification types
+// that differ from Icinga 2 onto the Icinga 2 notification types
+var naemonNotificationTypes = map[string]icingadsl.NotificationType{
+	"flappingstop":      icingadsl.FlappingEnd,
+	"flappingdisabled":  icingadsl.FlappingEnd,
+	"downtimecancelled": icingadsl.DowntimeRemoved,
+}
+
+// normalizeNotificationType returns the Icinga 2 notification type
+// for the notification type of the given dialect
+func normalizeNotificationType(dialect, nt string) (string, error) {
+	switch dialect {
+	case dialectIcinga2:
+		return nt, nil
+	case dialectNaemon:
+		t, ok := naemonNotificationTypes[strings.ToLower(nt)]
+
+		if !ok {
+			// All other types only differ in their casing,
+			// which is already handled by the Icinga 2 parser
+			return nt, nil
+		}
+
+		return icingadsl.FormatNotificationType(t)
+	default:
+		return nt, fmt.Errorf("unsupported input dialect '%s'. Currently supported: icinga2/naemon", dialect)
+	}
+}
+
+// dialectGetenv wraps the given getenv function to read the environment macros of the dialect.
+// Naemon exports its macros prefixed with NAGIOS_, the NOTIFY_ZAMMAD_* variables are never prefixed.
+func dialectGetenv(dialect string, getenv func(string) string) func(string) string {
+	if dialect != dialectNaemon {
+		return getenv
+	}
+
+	return func(name string) string {
+		if strings.HasPrefix(name, "NOTIFY_ZAMMAD_") {
+			return getenv(name)
+		}
+
+		return getenv("NAGIOS_" + name)
+	}
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/NETWAYS/go-icingadsl"
+
+	"github.com/NETWAYS/notify_zammad/internal/notifier"
+)
+
+func TestNormalizeNotificationType(t *testing.T) {
+	tests := map[string]icingadsl.NotificationType{
+		"PROBLEM":           icingadsl.Problem,
+		"RECOVERY":          icingadsl.Recovery,
+		"ACKNOWLEDGEMENT":   icingadsl.Acknowledgement,
+		"FLAPPINGSTART":     icingadsl.FlappingStart,
+		"FLAPPINGSTOP":      icingadsl.FlappingEnd,
+		"FLAPPINGDISABLED":  icingadsl.FlappingEnd,
+		"DOWNTIMESTART":     icingadsl.DowntimeStart,
+		"DOWNTIMEEND":       icingadsl.DowntimeEnd,
+		"DOWNTIMECANCELLED": icingadsl.DowntimeRemoved,
+		"CUSTOM":            icingadsl.Custom,
+	}
+
+	for nt, expected := range tests {
+		normalized, err := normalizeNotificationType(dialectNaemon, nt)
+
+		if err != nil {
+			t.Fatalf("Did not expect error for %s: %v", nt, err)
+		}
+
+		actual, err := icingadsl.ParseNotificationType(normalized)
+
+		if err != nil || actual != expected {
+			t.Error("\nActual: ", actual, err, "\nExpected: ", expected)
+		}
+	}
+
+	_, err := normalizeNotificationType("nagios4", "PROBLEM")
+
+	if err == nil {
+		t.Error("Expected unsupported dialect error")
+	}
+}
+
+func TestLoadNotificationFromNaemonEnv(t *testing.T) {
+	env := map[string]string{
+		"NAGIOS_NOTIFICATIONTYPE":   "FLAPPINGSTOP",
+		"NAGIOS_HOSTNAME":           "Host01",
+		"NAGIOS_HOSTSTATE":          "UP",
+		"NAGIOS_HOSTOUTPUT":         "PING OK - Packet loss = 0%",
+		"NAGIOS_NOTIFICATIONAUTHOR": "jon.snow",
+		"NOTIFY_ZAMMAD_GROUP":       "Users",
+		"HOSTNAME":                  "icinga-master",
+	}
+
+	n, err := loadNotification(inputEnv, notifier.Notification{}, nil, dialectGetenv(dialectNaemon, func(k string) string { return env[k] }))
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if n.IcingaHostname != "Host01" || n.IcingaCheckState != "UP" || n.IcingaAuthor != "jon.snow" {
+		t.Errorf("Expected notification from Naemon macros got: %v", n)
+	}
+
+	if n.ZammadGroup != "Users" {
+		t.Errorf("Expected NOTIFY_ZAMMAD_GROUP to be read got: %v", n)
+	}
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/NETWAYS/notify_zammad/internal/notifier"
+)
+
+const (
+	inputFlags = "flags"
+	inputEnv   = "env"
+	inputJSON  = "json"
+)
+
+// notificationField references a field of a notification and the environment variables
+// it is read from, the first variable that is set is used.
+type notificationField struct {
+	value *string
+	env   []string
+}
+
+// notificationFields returns the fields of the given notification.
+// The NOTIFY_ZAMMAD_* variables are preferred over the conventional names
+// used by the Icinga 2 notification scripts.
+func notificationFields(n *notifier.Notification) []notificationField {
+	return []notificationField{
+		{&n.IcingaNotificationType, []string{"NOTIFY_ZAMMAD_NOTIFICATION_TYPE", "NOTIFICATIONTYPE"}},
+		{&n.IcingaHostname, []string{"NOTIFY_ZAMMAD_HOST_NAME", "HOSTNAME"}},
+		{&n.IcingaServiceName, []string{"NOTIFY_ZAMMAD_SERVICE_NAME", "SERVICENAME", "SERVICEDESC"}},
+		{&n.IcingaCheckState, []string{"NOTIFY_ZAMMAD_CHECK_STATE", "SERVICESTATE", "HOSTSTATE"}},
+		{&n.IcingaCheckOutput, []string{"NOTIFY_ZAMMAD_CHECK_OUTPUT", "SERVICEOUTPUT", "HOSTOUTPUT"}},
+		{&n.IcingaAuthor, []string{"NOTIFY_ZAMMAD_NOTIFICATION_AUTHOR", "NOTIFICATIONAUTHORNAME", "NOTIFICATIONAUTHOR"}},
+		{&n.IcingaComment, []string{"NOTIFY_ZAMMAD_NOTIFICATION_COMMENT", "NOTIFICATIONCOMMENT"}},
+		{&n.IcingaDate, []string{"NOTIFY_ZAMMAD_NOTIFICATION_DATE", "LONGDATETIME"}},
+		{&n.IcingaProblemStart, []string{"NOTIFY_ZAMMAD_PROBLEM_START"}},
+		{&n.ZammadGroup, []string{"NOTIFY_ZAMMAD_GROUP"}},
+		{&n.ZammadCustomer, []string{"NOTIFY_ZAMMAD_CUSTOMER"}},
+		{&n.ZammadOrganization, []string{"NOTIFY_ZAMMAD_ORGANIZATION"}},
+		{&n.ZammadOwner, []string{"NOTIFY_ZAMMAD_OWNER"}},
+		{&n.ZammadPriority, []string{"NOTIFY_ZAMMAD_PRIORITY"}},
+	}
+}
+
+// loadNotification reads the notification from the given input source.
+// Fields already set in the given notification (e.g. via flags) take precedence
+// over the fields read from the input source.
+func loadNotification(input string, n notifier.Notification, stdin io.Reader, getenv func(string) string) (notifier.Notification, error) {
+	var source notifier.Notification
+
+	switch input {
+	case inputFlags:
+		return n, nil
+	case inputEnv:
+		for _, f := range notificationFields(&source) {
+			for _, name := range f.env {
+				if value := getenv(name); value != "" {
+					*f.value = value
+					break
+				}
+			}
+		}
+
+		// The host groups are a comma separated list like the Naemon macro
+		for _, name := range []string{"NOTIFY_ZAMMAD_HOST_GROUPS", "HOSTGROUPNAMES"} {
+			if value := getenv(name); value != "" {
+				source.IcingaHostGroups = strings.Split(value, ",")
+				break
+			}
+		}
+	case inputJSON:
+		err := json.NewDecoder(stdin).Decode(&source)
+
+		if err != nil {
+			return n, fmt.Errorf("unable to parse notification from stdin: %w", err)
+		}
+	default:
+		return n, fmt.Errorf("unsupported input '%s'. Currently supported: flags/env/json", input)
+	}
+
+	fields := notificationFields(&n)
+
+	for i, f := range notificationFields(&source) {
+		if *fields[i].value == "" {
+			*fields[i].value = *f.value
+		}
+	}
+
+	if n.Details == nil {
+		n.Details = source.Details
+	}
+
+	if n.ZammadTags == nil {
+		n.ZammadTags = source.ZammadTags
+	}
+
+	if n.IcingaHostGroups == nil {
+		n.IcingaHostGroups = source.IcingaHostGroups
+	}
+
+	if n.IcingaVars == nil {
+		n.IcingaVars = source.IcingaVars
+	}
+
+	if n.ZammadAttributes == nil {
+		n.ZammadAttributes = source.ZammadAttributes
+	}
+
+	return n, nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/NETWAYS/notify_zammad/internal/notifier"
+)
+
+func TestLoadNotificationFromEnv(t *testing.T) {
+	env := map[string]string{
+		"NOTIFICATIONTYPE":           "PROBLEM",
+		"HOSTNAME":                   "Host01",
+		"SERVICEDESC":                "hostalive",
+		"SERVICESTATE":               "CRITICAL",
+		"SERVICEOUTPUT":              "CRITICAL - host unreachable",
+		"NOTIFY_ZAMMAD_SERVICE_NAME": "ping4",
+		"NOTIFY_ZAMMAD_CUSTOMER":     "jon.snow@zammad",
+		"NOTIFY_ZAMMAD_GROUP":        "Users",
+		"HOSTGROUPNAMES":             "linux,webservers",
+	}
+
+	// Flags take precedence over the environment
+	flags := notifier.Notification{
+		ZammadGroup: "Admins",
+	}
+
+	n, err := loadNotification(inputEnv, flags, nil, func(k string) string { return env[k] })
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if n.IcingaHostname != "Host01" || n.IcingaNotificationType != "PROBLEM" || n.IcingaCheckState != "CRITICAL" {
+		t.Errorf("Expected notification from Icinga variables got: %v", n)
+	}
+
+	if n.IcingaServiceName != "ping4" {
+		t.Errorf("Expected NOTIFY_ZAMMAD_SERVICE_NAME to be preferred got: %s", n.IcingaServiceName)
+	}
+
+	if n.ZammadGroup != "Admins" || n.ZammadCustomer != "jon.snow@zammad" {
+		t.Errorf("Expected flags to take precedence got: %v", n)
+	}
+
+	if strings.Join(n.IcingaHostGroups, "|") != "linux|webservers" {
+		t.Errorf("Expected host groups from HOSTGROUPNAMES got: %v", n.IcingaHostGroups)
+	}
+
+	if err := n.Validate(); err != nil {
+		t.Errorf("Did not expect validation error: %v", err)
+	}
+}
+
+func TestLoadNotificationFromJSON(t *testing.T) {
+	stdin := strings.NewReader(`{
+  "notification_type": "Recovery",
+  "host_name": "Host01",
+  "check_state": "Up",
+  "check_output": "PING OK - Packet loss = 0%",
+  "zammad_group": "Users",
+  "zammad_attributes": {"icinga_zone": "master"}
+}`)
+
+	n, err := loadNotification(inputJSON, notifier.Notification{}, stdin, nil)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if n.IcingaNotificationType != "Recovery" || n.IcingaCheckOutput != "PING OK - Packet loss = 0%" {
+		t.Errorf("Expected notification from JSON got: %v", n)
+	}
+
+	if n.ZammadAttributes["icinga_zone"] != "master" {
+		t.Errorf("Expected attributes from JSON got: %v", n.ZammadAttributes)
+	}
+
+	err = n.Validate()
+
+	expected := "required field(s) zammad_customer not set"
+	if err == nil || err.Error() != expected {
+		t.Error("\nActual: ", err, "\nExpected: ", expected)
+	}
+}
+
+func TestLoadNotificationFromEnv_Hostname(t *testing.T) {
+	// The NotificationCommand exports HOSTNAME explicitly,
+	// it replaces the name of the Icinga node in the environment of the plugin
+	t.Setenv("HOSTNAME", "Host01")
+
+	n, _ := loadNotification(inputEnv, notifier.Notification{}, nil, os.Getenv)
+
+	if n.IcingaHostname != "Host01" {
+		t.Errorf("Expected host from HOSTNAME got: %s", n.IcingaHostname)
+	}
+
+	// The prefixed variable is preferred
+	t.Setenv("NOTIFY_ZAMMAD_HOST_NAME", "Host02")
+
+	n, _ = loadNotification(inputEnv, notifier.Notification{}, nil, os.Getenv)
+
+	if n.IcingaHostname != "Host02" {
+		t.Errorf("Expected host from NOTIFY_ZAMMAD_HOST_NAME got: %s", n.IcingaHostname)
+	}
+}
+
+func TestLoadNotificationErrors(t *testing.T) {
+	_, err := loadNotification("yaml", notifier.Notification{}, nil, nil)
+
+	if err == nil || !strings.Contains(err.Error(), "unsupported input") {
+		t.Errorf("Expected unsupported input error got: %v", err)
+	}
+
+	_, err = loadNotification(inputJSON, notifier.Notification{}, strings.NewReader(`{"host_name": `), nil)
+
+	if err == nil || !strings.Contains(err.Error(), "unable to parse notification") {
+		t.Errorf("Expected parse error got: %v", err)
+	}
+}
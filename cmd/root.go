@@ -2,17 +2,11 @@ package cmd
 
 import (
 	"context"
-	"errors"
-	"fmt"
-	"strings"
+	"os"
 	"time"
 
 	"github.com/NETWAYS/go-check"
-	"github.com/NETWAYS/go-icingadsl"
 	"github.com/spf13/cobra"
-
-	zammad "github.com/NETWAYS/notify_zammad/internal/api"
-	"github.com/NETWAYS/notify_zammad/internal/client"
 )
 
 // Timeout is the default timout for the plugin
@@ -50,6 +44,9 @@ func init() {
 
 	pfs := rootCmd.PersistentFlags()
 	// Configuration for the connection
+	pfs.StringSliceVar(&cliConfig.URLs, "zammad-url", nil,
+		"Base URL of the Zammad instance, including a path prefix (e.g. https://intranet.example/zammad/) or a unix:///path/to/socket.\n"+
+			"Can be repeated for standby instances, which are used if the previous ones fail")
 	pfs.StringVarP(&cliConfig.Hostname, "zammad-hostname", "H", "localhost",
 		"Address of the Zammad instance (NOTIFY_ZAMMAD_HOSTNAME)")
 	pfs.IntVarP(&cliConfig.Port, "zammad-port", "p", 443,
@@ -60,6 +57,20 @@ func init() {
 		"Token for server authentication (NOTIFY_ZAMMAD_TOKEN)")
 	pfs.StringVarP(&cliConfig.BasicAuth, "user", "u", "",
 		"Specify the user name and password for server authentication <user:password> (NOTIFY_ZAMMAD_BASICAUTH)")
+	pfs.StringVar(&cliConfig.OnBehalfOf, "on-behalf-of", "",
+		"Login, email or ID of the Zammad user all changes are made as, instead of the API user")
+	pfs.BoolVar(&cliConfig.OnBehalfOfAuthor, "on-behalf-of-author", false,
+		"Add the articles of Acknowledgement and Custom notifications as the Zammad user matching the notification author")
+	pfs.StringVar(&cliConfig.BearerToken, "bearer-token", "",
+		"Token sent as Authorization: Bearer, e.g. for an API gateway in front of Zammad")
+	pfs.StringVar(&cliConfig.OAuth2TokenURL, "oauth2-token-url", "",
+		"Token endpoint for the OAuth2 client credentials flow, the access token is sent as Authorization: Bearer")
+	pfs.StringVar(&cliConfig.OAuth2ClientID, "oauth2-client-id", "",
+		"Client ID for the OAuth2 client credentials flow")
+	pfs.StringVar(&cliConfig.OAuth2ClientSecret, "oauth2-client-secret", "",
+		"Client secret for the OAuth2 client credentials flow")
+	pfs.StringSliceVar(&cliConfig.OAuth2Scopes, "oauth2-scopes", nil,
+		"Scopes requested for the OAuth2 access token")
 	pfs.StringVarP(&cliConfig.CAFile, "ca-file", "", "",
 		"Specify the CA File for TLS authentication (NOTIFY_ZAMMAD_CA_FILE)")
 	pfs.StringVarP(&cliConfig.CertFile, "cert-file", "", "",
@@ -68,274 +79,180 @@ func init() {
 		"Specify the Key File for TLS authentication (NOTIFY_ZAMMAD_KEY_FILE)")
 	pfs.BoolVarP(&cliConfig.Insecure, "insecure", "i", false,
 		"Skip the verification of the server's TLS certificate")
+	pfs.StringVar(&cliConfig.TLSMinVersion, "tls-min-version", "",
+		"Minimum TLS version for the Zammad connection (1.0/1.1/1.2/1.3)")
+	pfs.StringVar(&cliConfig.TLSServerName, "tls-server-name", "",
+		"Server name used for SNI and the certificate verification, e.g. when connecting via IP")
+	pfs.StringSliceVar(&cliConfig.TLSPins, "tls-pin-sha256", nil,
+		"SHA-256 fingerprint of the server's certificate or public key to verify instead of the CAs, can be repeated")
 	pfs.IntVarP(&Timeout, "timeout", "t", Timeout,
 		"Timeout in seconds for the plugin")
-
-	rootCmd.MarkFlagsMutuallyExclusive("user", "token")
-
-	// Configuration for the notification
-	pfs.StringVar(&cliConfig.IcingaHostname, "host-name", "",
+	pfs.BoolVar(&cliConfig.Debug, "debug", false,
+		"Log all API requests and responses with credentials redacted")
+	pfs.StringVar(&cliConfig.LogFile, "log-file", "",
+		"Write the logs to this file instead of stderr, API requests are only logged with --debug")
+	pfs.StringVar(&cliConfig.LogFormat, "log-format", "text",
+		"Format of the logs (text/json)")
+	pfs.BoolVar(&cliConfig.Lookup, "zammad-lookup", false,
+		"Look up group and customer in Zammad before creating a ticket, they are accepted by name, email, login or id:N")
+	pfs.BoolVar(&cliConfig.CreateCustomer, "zammad-create-customer", false,
+		"Create the customer if no Zammad user with the given email exists")
+	pfs.StringVar(&cliConfig.AssignStrategy, "zammad-assign", "",
+		"Strategy picking the owner of new tickets without owner from --zammad-agents (round-robin/least-open)")
+	pfs.StringSliceVar(&cliConfig.AssignAgents, "zammad-agents", nil,
+		"Email, login or id:N of the Zammad agents new tickets are assigned to")
+	pfs.BoolVar(&cliConfig.AssignAuthor, "zammad-assign-author", false,
+		"Assign the ticket to the Zammad user matching the author of Acknowledgement notifications")
+	pfs.BoolVar(&cliConfig.UpdateAttributes, "zammad-update-attributes", false,
+		"Set the attributes of the notification on existing tickets as well, not only on new tickets")
+	pfs.StringToStringVar(&cliConfig.VarAttributes, "var-attribute", nil,
+		"Map a custom variable to a ticket attribute as var=attribute, kept up to date on existing tickets, can be repeated")
+	pfs.StringToStringVar(&cliConfig.VarDetails, "var-detail", nil,
+		"Map a custom variable to a line in the articles as var=label, can be repeated")
+	pfs.BoolVar(&cliConfig.LinkHostTickets, "link-host-tickets", false,
+		"Link the tickets of service problems as children to the open ticket of their host")
+	pfs.BoolVar(&cliConfig.MergeServiceProblems, "merge-service-problems", false,
+		"Add service notifications as articles to the open ticket of their host instead of creating a ticket per service")
+	pfs.StringToStringVar(&cliConfig.ArticleTypes, "article-type", nil,
+		"Type of the articles by notification type as notification=type (e.g. recovery=note), email sends the article, default web")
+	pfs.StringToStringVar(&cliConfig.ArticleSenders, "article-sender", nil,
+		"Sender of the articles by notification type as notification=sender (Agent/Customer/System), default Agent")
+	pfs.StringSliceVar(&cliConfig.ArticlePublic, "article-public", nil,
+		"Notification types whose articles are visible to the customer (e.g. recovery), articles are internal by default")
+	pfs.StringToStringVar(&cliConfig.ArticleTo, "article-to", nil,
+		"Recipient of email articles by notification type as notification=address, default the customer")
+	pfs.BoolVar(&cliConfig.TimeAccounting, "time-accounting", false,
+		"Account the outage duration with Recovery articles and the minutes given as #time N in acknowledgement comments")
+	pfs.DurationVar(&cliConfig.TimeRounding, "time-rounding", 0,
+		"Round the accounted time up to a multiple of this duration (e.g. 15m)")
+	pfs.Float64Var(&cliConfig.TimeFactor, "time-factor", 1,
+		"Factor converting the accounted minutes into the time unit used in Zammad (e.g. 0.016667 for hours)")
+	pfs.StringVar(&cliConfig.CacheFile, "cache-file", "",
+		"File caching the IDs looked up in Zammad (default $XDG_CACHE_HOME/notify_zammad/ids.json)")
+	pfs.DurationVar(&cliConfig.CacheTTL, "cache-ttl", time.Hour,
+		"Time the IDs looked up in Zammad are cached with --zammad-lookup, 0 disables the cache")
+	pfs.StringVar(&cliConfig.RoutingFile, "routing-file", "",
+		"JSON file with rules choosing group, customer, organization, owner, priority and tags of new tickets, values set explicitly take precedence")
+
+	// Configuration for the optional Icinga 2 API connection
+	pfs.StringVar(&cliConfig.IcingaAPIHostname, "icinga-hostname", "localhost",
+		"Address of the Icinga 2 API (NOTIFY_ZAMMAD_ICINGA_HOSTNAME)")
+	pfs.IntVar(&cliConfig.IcingaAPIPort, "icinga-port", 5665,
+		"Port of the Icinga 2 API")
+	pfs.StringVar(&cliConfig.IcingaAPIBasicAuth, "icinga-user", "",
+		"Specify the user name and password for the Icinga 2 API <user:password> (NOTIFY_ZAMMAD_ICINGA_BASICAUTH)")
+	pfs.StringVar(&cliConfig.IcingaAPICAFile, "icinga-ca-file", "",
+		"Specify the CA File for TLS authentication with the Icinga 2 API (NOTIFY_ZAMMAD_ICINGA_CA_FILE)")
+	pfs.StringVar(&cliConfig.IcingaAPICertFile, "icinga-cert-file", "",
+		"Specify the Certificate File for TLS authentication with the Icinga 2 API (NOTIFY_ZAMMAD_ICINGA_CERT_FILE)")
+	pfs.StringVar(&cliConfig.IcingaAPIKeyFile, "icinga-key-file", "",
+		"Specify the Key File for TLS authentication with the Icinga 2 API (NOTIFY_ZAMMAD_ICINGA_KEY_FILE)")
+	pfs.BoolVar(&cliConfig.IcingaAPIInsecure, "icinga-insecure", false,
+		"Skip the verification of the Icinga 2 API's TLS certificate")
+
+	// Configuration for the notification, these are only used by the root command
+	fs := rootCmd.Flags()
+	fs.StringVar(&cliConfig.Input, "input", inputFlags,
+		"Source of the notification data (flags/env/json), flags take precedence over env and json from stdin")
+	fs.StringVar(&cliConfig.InputDialect, "input-dialect", dialectIcinga2,
+		"Monitoring system sending the notification (icinga2/naemon), naemon reads the NAGIOS_* environment macros")
+	fs.StringVar(&cliConfig.IcingaHostname, "host-name", "",
 		"Host name of the Icinga 2 Host object")
-	pfs.StringVar(&cliConfig.IcingaServiceName, "service-name", "",
+	fs.StringVar(&cliConfig.IcingaServiceName, "service-name", "",
 		"Service name of the Icinga 2 Service Object (optional for Host Notifications)")
-	pfs.StringVar(&cliConfig.IcingaCheckState, "check-state", "",
+	fs.StringVar(&cliConfig.IcingaCheckState, "check-state", "",
 		"State of the Object (Up/Down for hosts, OK/Warning/Critical/Unknown for services)")
-	pfs.StringVar(&cliConfig.IcingaCheckOutput, "check-output", "",
+	fs.StringVar(&cliConfig.IcingaCheckOutput, "check-output", "",
 		"Output of the last executed check")
-	pfs.StringVar(&cliConfig.IcingaNotificationType, "notification-type", "",
+	fs.StringVar(&cliConfig.IcingaNotificationType, "notification-type", "",
 		"Type of the notification (Problem/Recovery/Acknowledgement)")
-	pfs.StringVar(&cliConfig.IcingaAuthor, "notification-author", "",
+	fs.StringVar(&cliConfig.IcingaAuthor, "notification-author", "",
 		"Name of an author for manual events")
-	pfs.StringVar(&cliConfig.IcingaComment, "notification-comment", "",
+	fs.StringVar(&cliConfig.IcingaComment, "notification-comment", "",
 		"Comment for manual events")
-	pfs.StringVar(&cliConfig.IcingaDate, "notification-date", "",
+	fs.StringVar(&cliConfig.IcingaDate, "notification-date", "",
 		"Date when the event occurred")
-	pfs.StringVar(&cliConfig.ZammadGroup, "zammad-group", "",
+	fs.StringVar(&cliConfig.IcingaProblemStart, "problem-start", "",
+		"Start of the problem as Unix timestamp or date (e.g. $service.previous_state_change$), used by the time accounting of recoveries")
+	fs.StringSliceVar(&cliConfig.IcingaHostGroups, "host-groups", nil,
+		"Host groups of the Icinga 2 Host object, used by the routing rules")
+	fs.StringToStringVar(&cliConfig.IcingaVars, "var", nil,
+		"Custom variable of the object as key=value used by the routing rules, can be repeated")
+	fs.StringVar(&cliConfig.ZammadGroup, "zammad-group", "",
 		"Custom Zammad Field for the group")
-	pfs.StringVar(&cliConfig.ZammadCustomer, "zammad-customer", "",
+	fs.StringVar(&cliConfig.ZammadCustomer, "zammad-customer", "",
 		"Custom Zammad Field for the customer")
+	fs.StringVar(&cliConfig.ZammadOrganization, "zammad-organization", "",
+		"Zammad organization of new tickets, by default the customer's organization is used")
+	fs.StringVar(&cliConfig.ZammadOwner, "zammad-owner", "",
+		"Email, login or id:N of the Zammad agent new tickets are assigned to")
+	fs.StringToStringVar(&cliConfig.ZammadAttributes, "zammad-attribute", nil,
+		"Object manager attribute of the ticket as key=value (e.g. icinga_zone=master), can be repeated")
+	fs.BoolVar(&cliConfig.IcingaAcknowledge, "icinga-acknowledge", false,
+		"Acknowledge the Icinga problem via the Icinga 2 API when a ticket is created")
+	fs.StringVar(&cliConfig.IcingaAPIAuthor, "icinga-author", "notify_zammad",
+		"Author of the acknowledgement in Icinga")
+	fs.BoolVar(&cliConfig.DryRun, "dry-run", false,
+		"Search for tickets, but only print the changes instead of sending them to Zammad")
+
+	fs.SortFlags = false
+	pfs.SortFlags = false
 
-	_ = cobra.MarkFlagRequired(pfs, "notification-type")
-	_ = cobra.MarkFlagRequired(pfs, "host-name")
-	_ = cobra.MarkFlagRequired(pfs, "check-state")
-	_ = cobra.MarkFlagRequired(pfs, "check-output")
-	_ = cobra.MarkFlagRequired(pfs, "zammad-group")
-	_ = cobra.MarkFlagRequired(pfs, "zammad-customer")
+	rootCmd.MarkFlagsMutuallyExclusive("user", "token", "bearer-token", "oauth2-token-url")
+	rootCmd.MarkFlagsRequiredTogether("oauth2-token-url", "oauth2-client-id", "oauth2-client-secret")
+	rootCmd.MarkFlagsRequiredTogether("zammad-assign", "zammad-agents")
 
-	rootCmd.Flags().SortFlags = false
-	pfs.SortFlags = false
+	// The host, port and secure flags are replaced by the base URL
+	_ = pfs.MarkDeprecated("zammad-hostname", "use --zammad-url instead")
+	_ = pfs.MarkDeprecated("zammad-port", "use --zammad-url instead")
+	_ = pfs.MarkDeprecated("secure", "use --zammad-url instead")
+
+	// Credentials can be passed in the environment, so they are not visible in the process list.
+	// The flags take precedence since they are parsed afterwards.
+	check.LoadFromEnv(&cliConfig)
 }
 
 // sendNotification is the cobra.Command that is executed
 func sendNotification(_ *cobra.Command, _ []string) {
-	notificationType, err := icingadsl.ParseNotificationType(cliConfig.IcingaNotificationType)
+	n, err := loadNotification(cliConfig.Input, cliConfig.Notification, os.Stdin, dialectGetenv(cliConfig.InputDialect, os.Getenv))
 
 	if err != nil {
-		check.ExitError(errors.New("unsupported notification type. Currently supported: Problem/Recovery/Acknowledgement"))
+		check.ExitError(err)
 	}
 
-	// Creating an client and connecting to the API
-	c := cliConfig.NewClient()
-
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(Timeout)*time.Second)
-	defer cancel()
-
-	// Search for existing Tickets
-	tickets, err := c.SearchTickets(ctx, cliConfig.IcingaHostname, cliConfig.IcingaServiceName)
+	n.IcingaNotificationType, err = normalizeNotificationType(cliConfig.InputDialect, n.IcingaNotificationType)
 
 	if err != nil {
 		check.ExitError(err)
 	}
 
-	var ticket zammad.Ticket
-
-	var notificationErr error
-
-	foundTicket := false
-
-	if len(tickets) > 0 {
-		// Using the first ticket found for the notification,
-		// the SearchTickets methods returns the tickets by created_at.
-		// If no ticket is found the zammad.Ticket type will be empty,
-		// which can be used to detect if a new ticket needs to be created.
-		ticket = tickets[0]
-
-		foundTicket = true
-	}
-
-	switch notificationType {
-	case icingadsl.Custom:
-		// If ticket exists, adds article to existing ticket
-		notificationErr = handleCustomNotification(ctx, c, ticket, "Custom")
-	case icingadsl.Acknowledgement:
-		// If ticket exists, adds article to existing ticket
-		notificationErr = handleAcknowledgeNotification(ctx, c, ticket)
-	case icingadsl.Problem:
-		// Opens a new ticket if none exists
-		// If one exists, adds article to existing ticket
-		notificationErr = handleProblemNotification(ctx, c, ticket.ID, foundTicket)
-	case icingadsl.Recovery:
-		// Closes a ticket if one exists
-		// If ticket is open, adds article to existing ticket
-		// If ticket is closed, reopens the ticket with the article
-		notificationErr = handleRecoveryNotification(ctx, c, ticket)
-	case icingadsl.DowntimeStart:
-		// If ticket exists, adds article to existing ticket
-		notificationErr = handleCustomNotification(ctx, c, ticket, "DowntimeStart")
-	case icingadsl.DowntimeEnd:
-		// If ticket exists, adds article to existing ticket
-		notificationErr = handleCustomNotification(ctx, c, ticket, "DowntimeEnd")
-	case icingadsl.DowntimeRemoved:
-		// If ticket exists, adds article to existing ticket
-		notificationErr = handleCustomNotification(ctx, c, ticket, "DowntimeRemoved")
-	case icingadsl.FlappingStart:
-		// If ticket exists, adds article to existing ticket
-		notificationErr = handleCustomNotification(ctx, c, ticket, "FlappingStart")
-	case icingadsl.FlappingEnd:
-		// If ticket exists, adds article to existing ticket
-		notificationErr = handleCustomNotification(ctx, c, ticket, "FlappingEnd")
-	default:
-		check.ExitError(errors.New("unsupported notification type. Currently supported: Problem/Recovery/Acknowledgement"))
-	}
-
-	if notificationErr != nil {
-		check.ExitError(notificationErr)
-	}
-
-	check.BaseExit(0)
-}
-
-// createArticleBody is a small util function to create an articles HTML body
-func createArticleBody(header string) string {
-	var b strings.Builder
-
-	b.WriteString(fmt.Sprintf("<h3>%s</h3>", header))
-	b.WriteString(fmt.Sprintf("<p>Check State: %s</p>", cliConfig.IcingaCheckState))
-	b.WriteString(fmt.Sprintf("<p>Check Output: %s</p>", cliConfig.IcingaCheckOutput))
-
-	if cliConfig.IcingaAuthor != "" {
-		b.WriteString(fmt.Sprintf("<p>Notification Author: %s</p>", cliConfig.IcingaAuthor))
-	}
-
-	if cliConfig.IcingaDate != "" {
-		b.WriteString(fmt.Sprintf("<p>Notification Date: %s</p>", cliConfig.IcingaDate))
-	}
-
-	if cliConfig.IcingaComment != "" {
-		b.WriteString(fmt.Sprintf("<p>Notification Comment: %s</p>", cliConfig.IcingaComment))
-	}
-
-	return b.String()
-}
-
-// handleProblemNotification opens a new ticket if none exists,
-// If one exists, adds message to existing ticket.
-func handleProblemNotification(ctx context.Context, c *client.Client, ticketID int, ticketExists bool) error {
-	var err error
+	rule := n.Route(cliConfig.Rules())
 
-	a := zammad.Article{
-		Subject:     "Problem",
-		Body:        createArticleBody("Problem"),
-		ContentType: "text/html",
-		Type:        "web",
-		Internal:    true,
-		Sender:      "Agent",
+	if cliConfig.RoutingFile != "" {
+		cliConfig.Logger().Debug("routed notification", "rule", rule, "group", n.ZammadGroup, "customer", n.ZammadCustomer)
 	}
 
-	// If a Zammad Ticket exists, add the article to this ticket.
-	if ticketExists {
-		a.TicketID = ticketID
-		err = c.AddArticleToTicket(ctx, a)
-
-		return err
-	}
-
-	// Open a new Ticket with the given data
-	var title strings.Builder
-
-	title.WriteString("[Problem] ")
-
-	title.WriteString("State: " + cliConfig.IcingaCheckState + " for")
-	title.WriteString(" Host: " + cliConfig.IcingaHostname)
-
-	if cliConfig.IcingaServiceName != "" {
-		title.WriteString(" Service: " + cliConfig.IcingaServiceName)
-	}
-
-	ticket := zammad.NewTicket{}
-
-	ticket.Title = title.String()
-	ticket.Group = cliConfig.ZammadGroup
-	ticket.Customer = cliConfig.ZammadCustomer
-	ticket.IcingaHost = cliConfig.IcingaHostname
-	ticket.IcingaService = cliConfig.IcingaServiceName
-	ticket.Article = a
-
-	err = c.CreateTicket(ctx, ticket)
-
-	return err
-}
-
-// handleAcknowledgeNotification adds a new article to an existing ticket
-// If the ticket is in state new, it will be set to state open
-// If no ticket exists an error is returned
-func handleAcknowledgeNotification(ctx context.Context, c *client.Client, ticket zammad.Ticket) error {
-	// If no Zammad Ticket exists, we cannot add an article and thus return an error
-	// and notify the user
-	if ticket.ID == 0 {
-		return errors.New("no open or new ticket found to add acknowledgement article to")
-	}
-
-	a := zammad.Article{
-		TicketID:    ticket.ID,
-		Subject:     "Acknowledgement",
-		Body:        createArticleBody("Acknowledgement"),
-		ContentType: "text/html",
-		Type:        "web",
-		Internal:    true,
-		Sender:      "Agent",
-	}
-
-	err := c.AddArticleToTicket(ctx, a)
+	err = n.Validate()
 
 	if err != nil {
-		return err
+		check.ExitError(err)
 	}
 
-	// Update the ticket state to open
-	err = c.UpdateTicketState(ctx, ticket, zammad.OpenTicketState)
-
-	return err
-}
-
-// handleRecoveryNotification closes an existing ticket
-// If the existing ticket is open, adds an article to ticket and sets the state to closed
-// If ticket is closed, reopens the ticket with an article
-func handleRecoveryNotification(ctx context.Context, c *client.Client, ticket zammad.Ticket) error {
-	if ticket.ID == 0 {
-		return errors.New("no open or new ticket found to add recovery article to")
-	}
+	// Creating an client and connecting to the API
+	nt := cliConfig.NewNotifier()
 
-	a := zammad.Article{
-		TicketID:    ticket.ID,
-		Subject:     "Recovery",
-		Body:        createArticleBody("Recovery"),
-		ContentType: "text/html",
-		Type:        "web",
-		Internal:    true,
-		Sender:      "Agent",
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(Timeout)*time.Second)
+	defer cancel()
 
-	err := c.AddArticleToTicket(ctx, a)
+	result, err := nt.Process(ctx, n)
 
 	if err != nil {
-		return err
-	}
-
-	// Update the ticket state to open
-	err = c.UpdateTicketState(ctx, ticket, zammad.ClosedTicketState)
-
-	return err
-}
-
-// handleCustomNotification adds an article to an existing ticket
-// If no ticket exists nothing happens and the function returns
-func handleCustomNotification(ctx context.Context, c *client.Client, ticket zammad.Ticket, notificationType string) error {
-	if ticket.ID == 0 {
-		return nil
+		check.ExitError(err)
 	}
 
-	a := zammad.Article{
-		TicketID:    ticket.ID,
-		Subject:     notificationType,
-		Body:        createArticleBody(notificationType),
-		ContentType: "text/html",
-		Type:        "web",
-		Internal:    true,
-		Sender:      "Agent",
+	if cliConfig.DryRun {
+		check.ExitRaw(check.OK, "dry-run, no changes were sent to Zammad")
 	}
 
-	err := c.AddArticleToTicket(ctx, a)
-
-	return err
+	check.ExitRaw(check.OK, result.String()+cliConfig.usedEndpoint())
 }
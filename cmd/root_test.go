@@ -9,20 +9,6 @@ import (
 	"testing"
 )
 
-func TestCreateArticleBody(t *testing.T) {
-	actual := createArticleBody("foo")
-	expected := "<h3>foo</h3>"
-
-	if !strings.Contains(actual, expected) {
-		t.Error("\nActual: ", actual, "\nExpected: ", expected)
-	}
-
-	expected = "<p>Check State: </p>"
-	if !strings.Contains(actual, expected) {
-		t.Error("\nActual: ", actual, "\nExpected: ", expected)
-	}
-}
-
 func TestNotify_ConnectionRefused(t *testing.T) {
 
 	cmd := exec.Command("go", "run", "../main.go", "--zammad-port", "9999", "--notification-type", "Problem", "--host-name", "foo", "--check-state", "foo", "--check-output", "foo", "--zammad-group", "foo", "--zammad-customer", "foo")
@@ -52,7 +38,7 @@ func TestNotifyZammad(t *testing.T) {
 				w.Write([]byte(`{}`))
 			})),
 			args:     []string{"run", "../main.go"},
-			expected: "[UNKNOWN] - required flag",
+			expected: "[UNKNOWN] - required field(s) notification_type, host_name, check_state, check_output, zammad_group, zammad_customer not set",
 		},
 		{
 			name: "with-wrong-auth",
@@ -76,6 +62,19 @@ func TestNotifyZammad(t *testing.T) {
 			args:     []string{"run", "../main.go", "--token", "foo", "--notification-type", "NoSuchType", "--host-name", "Host01", "--service-name", "hostalive", "--check-state", "Down", "--check-output", "CRITICAL - host unreachable", "--zammad-group", "Users", "--zammad-customer", "jon.snow@zammad"},
 			expected: "[UNKNOWN] - unsupported notification type",
 		},
+		{
+			name: "with-dry-run",
+			server: httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodGet {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`[]`))
+			})),
+			args:     []string{"run", "../main.go", "--dry-run", "--token", "foo", "--notification-type", "Problem", "--host-name", "Host01", "--service-name", "hostalive", "--check-state", "Down", "--check-output", "CRITICAL - host unreachable", "--zammad-group", "Users", "--zammad-customer", "jon.snow@zammad"},
+			expected: "\"title\": \"[Problem] State: Down for Host: Host01 Service: hostalive\"",
+		},
 	}
 
 	for _, test := range tests {
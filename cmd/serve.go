@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/NETWAYS/go-check"
+	"github.com/spf13/cobra"
+
+	"github.com/NETWAYS/notify_zammad/internal/alertmanager"
+	"github.com/NETWAYS/notify_zammad/internal/dispatch"
+	"github.com/NETWAYS/notify_zammad/internal/notifier"
+	"github.com/NETWAYS/notify_zammad/internal/routing"
+)
+
+// ServeConfig holds the configuration for the serve subcommand
+type ServeConfig struct {
+	Listen   string
+	Token    string `env:"NOTIFY_ZAMMAD_LISTEN_TOKEN"`
+	CertFile string
+	KeyFile  string
+
+	Workers   int
+	QueueSize int
+}
+
+var serveConfig ServeConfig
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Receive notifications over HTTP",
+	Long: `Receive notifications over HTTP
+
+Runs a long-lived service that accepts notifications as JSON via
+POST /v1/notifications and processes them like the plugin would.
+Notifications for the same host and service are processed one after another.
+
+Prometheus Alertmanager webhook messages are accepted via POST /v1/alertmanager.`,
+	// The timeout is applied to each notification, not the whole process
+	PersistentPreRun: func(_ *cobra.Command, _ []string) {},
+	Run:              runServe,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	fs := serveCmd.Flags()
+	fs.StringVar(&serveConfig.Listen, "listen", "localhost:8080",
+		"Address to listen on for notifications")
+	fs.StringVar(&serveConfig.Token, "listen-token", "",
+		"Token clients have to send as Bearer token (NOTIFY_ZAMMAD_LISTEN_TOKEN)")
+	fs.StringVar(&serveConfig.CertFile, "listen-cert-file", "",
+		"Specify the Certificate File to serve HTTPS")
+	fs.StringVar(&serveConfig.KeyFile, "listen-key-file", "",
+		"Specify the Key File to serve HTTPS")
+	fs.IntVar(&serveConfig.Workers, "workers", 4,
+		"Number of notifications processed in parallel")
+	fs.IntVar(&serveConfig.QueueSize, "queue-size", 100,
+		"Number of notifications each worker queues before new ones are rejected")
+	fs.BoolVar(&cliConfig.IcingaAcknowledge, "icinga-acknowledge", false,
+		"Acknowledge the Icinga problem via the Icinga 2 API when a ticket is created")
+	fs.StringVar(&cliConfig.IcingaAPIAuthor, "icinga-author", "notify_zammad",
+		"Author of the acknowledgement in Icinga")
+
+	addAlertmanagerFlags(fs)
+
+	// The token is read from the environment as well, so it is not visible in the process list.
+	// The flag takes precedence since it is parsed afterwards.
+	check.LoadFromEnv(&serveConfig)
+
+	serveCmd.MarkFlagsRequiredTogether("listen-cert-file", "listen-key-file")
+
+	fs.SortFlags = false
+}
+
+// runServe is the cobra.Command that is executed for the serve subcommand
+func runServe(_ *cobra.Command, _ []string) {
+	if serveConfig.Token == "" {
+		check.ExitError(errors.New("a token for the listener is required, set --listen-token or NOTIFY_ZAMMAD_LISTEN_TOKEN"))
+	}
+
+	// The service logs honor the log flags as well
+	slog.SetDefault(cliConfig.Logger())
+
+	// The notifier is shared by all workers
+	nt := cliConfig.NewNotifier()
+
+	alertmanagerConfig.Rules = cliConfig.Rules()
+
+	d := dispatch.New(serveConfig.Workers, serveConfig.QueueSize)
+
+	h := &notificationHandler{
+		token:      serveConfig.Token,
+		dispatcher: d,
+		rules:      alertmanagerConfig.Rules,
+		// The host ticket is looked up or linked by the notifications of its services
+		byHost: cliConfig.LinkHostTickets || cliConfig.MergeServiceProblems,
+		process: func(n notifier.Notification) error {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Duration(Timeout)*time.Second)
+			defer cancel()
+
+			_, err := nt.Process(ctx, n)
+
+			return err
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/v1/notifications", h)
+	mux.Handle("/v1/alertmanager", &alertmanagerHandler{
+		notificationHandler: h,
+		config:              alertmanagerConfig,
+	})
+
+	server := &http.Server{
+		Addr:              serveConfig.Listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 1)
+
+	go func() {
+		slog.Info("listening for notifications", "address", serveConfig.Listen)
+
+		if serveConfig.CertFile != "" {
+			errs <- server.ListenAndServeTLS(serveConfig.CertFile, serveConfig.KeyFile)
+			return
+		}
+
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		d.Close()
+		check.ExitError(err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, waiting for queued notifications")
+
+	// Stop accepting requests, then process all notifications that are already queued
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(Timeout)*time.Second)
+	defer cancel()
+
+	err := server.Shutdown(shutdownCtx)
+
+	d.Close()
+
+	if err != nil {
+		check.ExitError(err)
+	}
+}
+
+// notificationHandler receives notifications as JSON and
+// hands them to the dispatcher
+type notificationHandler struct {
+	token      string
+	dispatcher *dispatch.Dispatcher
+	process    func(notifier.Notification) error
+	// rules route the notifications before they are validated, optional
+	rules *routing.Rules
+	// byHost processes all notifications of a host one after another,
+	// required if the tickets of services depend on the ticket of their host
+	byHost bool
+}
+
+// notificationResponse is returned to the client for each notification
+type notificationResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// authorize checks the method and token of the request,
+// if the request is not allowed the response is written.
+func (h *notificationHandler) authorize(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeResponse(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+
+		return false
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		writeResponse(w, http.StatusUnauthorized, errors.New("invalid token"))
+		return false
+	}
+
+	return true
+}
+
+// key returns the key of the notification for the dispatcher
+func (h *notificationHandler) key(n notifier.Notification) string {
+	if h.byHost {
+		return n.IcingaHostname
+	}
+
+	return n.Key()
+}
+
+// submit hands the notification to the dispatcher and waits for the result
+func (h *notificationHandler) submit(r *http.Request, n notifier.Notification) error {
+	err := h.dispatcher.Submit(r.Context(), h.key(n), func() error {
+		return h.process(n)
+	})
+
+	if err != nil {
+		slog.Error("could not process notification", "host", n.IcingaHostname, "service", n.IcingaServiceName, "error", err)
+	}
+
+	return err
+}
+
+func (h *notificationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r) {
+		return
+	}
+
+	var n notifier.Notification
+
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&n)
+
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	n.Route(h.rules)
+
+	err = n.Validate()
+
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	err = h.submit(r, n)
+
+	writeResponse(w, statusForError(err), err)
+}
+
+// alertmanagerHandler receives Alertmanager webhook messages and
+// hands each alert as notification to the dispatcher
+type alertmanagerHandler struct {
+	*notificationHandler
+	config AlertmanagerConfig
+}
+
+func (h *alertmanagerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorize(w, r) {
+		return
+	}
+
+	m, err := alertmanager.Decode(http.MaxBytesReader(w, r.Body, 1<<20))
+
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, err)
+		return
+	}
+
+	errs := make([]error, 0)
+	status := http.StatusOK
+
+	// Each alert is handled on its own, a failing alert does not stop the others
+	for _, a := range m.Alerts {
+		n, err := alertToNotification(a, h.config)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alert %s: %w", a.Fingerprint, err))
+			status = max(status, http.StatusBadRequest)
+
+			continue
+		}
+
+		err = h.submit(r, n)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alert %s: %w", a.Fingerprint, err))
+			status = max(status, statusForError(err))
+		}
+	}
+
+	writeResponse(w, status, errors.Join(errs...))
+}
+
+// statusForError returns the HTTP status for the result of a notification
+func statusForError(err error) int {
+	switch {
+	case errors.Is(err, dispatch.ErrQueueFull), errors.Is(err, dispatch.ErrClosed):
+		return http.StatusServiceUnavailable
+	// Retrying a recovery without ticket would fail again, the error is only reported
+	case errors.Is(err, notifier.ErrNoRecoveryTicket):
+		return http.StatusOK
+	case err != nil:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusOK
+	}
+}
+
+// writeResponse writes the status and optional error as JSON
+func writeResponse(w http.ResponseWriter, status int, err error) {
+	resp := notificationResponse{
+		Status: "ok",
+	}
+
+	if err != nil {
+		resp.Status = "error"
+		resp.Error = err.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/NETWAYS/go-check"
+	"github.com/spf13/cobra"
+
+	"github.com/NETWAYS/notify_zammad/internal/dispatch"
+	"github.com/NETWAYS/notify_zammad/internal/notifier"
+)
+
+type ServeTest struct {
+	name     string
+	token    string
+	body     string
+	expected int
+}
+
+func TestNotificationHandler(t *testing.T) {
+	d := dispatch.New(2, 10)
+	defer d.Close()
+
+	var received []notifier.Notification
+
+	h := &notificationHandler{
+		token:      "secret",
+		dispatcher: d,
+		process: func(n notifier.Notification) error {
+			if n.IcingaHostname == "BrokenHost" {
+				return errors.New("could not create ticket")
+			}
+
+			if n.IcingaNotificationType == "Recovery" {
+				return notifier.ErrNoRecoveryTicket
+			}
+
+			received = append(received, n)
+
+			return nil
+		},
+	}
+
+	tests := []ServeTest{
+		{
+			name:     "with-wrong-token",
+			token:    "foo",
+			body:     `{}`,
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "with-invalid-json",
+			token:    "secret",
+			body:     `{"host_name": `,
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "with-missing-fields",
+			token:    "secret",
+			body:     `{"host_name": "Host01", "notification_type": "Problem"}`,
+			expected: http.StatusBadRequest,
+		},
+		{
+			name:     "with-processing-error",
+			token:    "secret",
+			body:     `{"notification_type": "Problem", "host_name": "BrokenHost", "check_state": "Down", "check_output": "CRITICAL - host unreachable", "zammad_group": "Users", "zammad_customer": "jon.snow@zammad"}`,
+			expected: http.StatusInternalServerError,
+		},
+		{
+			name:     "with-recovery-without-ticket",
+			token:    "secret",
+			body:     `{"notification_type": "Recovery", "host_name": "Host01", "check_state": "Up", "check_output": "OK - host reachable", "zammad_group": "Users", "zammad_customer": "jon.snow@zammad"}`,
+			expected: http.StatusOK,
+		},
+		{
+			name:     "with-notification",
+			token:    "secret",
+			body:     `{"notification_type": "Problem", "host_name": "Host01", "service_name": "hostalive", "check_state": "Down", "check_output": "CRITICAL - host unreachable", "zammad_group": "Users", "zammad_customer": "jon.snow@zammad"}`,
+			expected: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/notifications", strings.NewReader(test.body))
+			req.Header.Set("Authorization", "Bearer "+test.token)
+
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			if w.Code != test.expected {
+				t.Error("\nActual: ", w.Code, w.Body.String(), "\nExpected: ", test.expected)
+			}
+		})
+	}
+
+	if len(received) != 1 || received[0].IcingaServiceName != "hostalive" {
+		t.Errorf("Expected one processed notification got: %v", received)
+	}
+}
+
+func TestAlertmanagerHandler(t *testing.T) {
+	d := dispatch.New(2, 10)
+	defer d.Close()
+
+	var received []notifier.Notification
+
+	h := &alertmanagerHandler{
+		notificationHandler: &notificationHandler{
+			token:      "secret",
+			dispatcher: d,
+			process: func(n notifier.Notification) error {
+				if n.IcingaNotificationType == "Recovery" {
+					return notifier.ErrNoRecoveryTicket
+				}
+
+				received = append(received, n)
+
+				return nil
+			},
+		},
+		config: AlertmanagerConfig{
+			HostLabel:      "instance",
+			ServiceLabel:   "alertname",
+			StateLabel:     "severity",
+			ZammadGroup:    "Users",
+			ZammadCustomer: "jon.snow@zammad",
+		},
+	}
+
+	body := `{"status": "firing", "alerts": [
+  {"status": "firing", "labels": {"alertname": "InstanceDown", "instance": "host01:9100"}, "fingerprint": "c5a5b3a3b9e6f0d1"},
+  {"status": "resolved", "labels": {"alertname": "InstanceDown"}, "fingerprint": "a1b2c3d4e5f60718"}
+]}`
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/alertmanager", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "a1b2c3d4e5f60718") {
+		t.Error("\nActual: ", w.Code, w.Body.String(), "\nExpected: ", http.StatusBadRequest)
+	}
+
+	if len(received) != 1 || received[0].IcingaHostname != "host01:9100" {
+		t.Errorf("Expected the valid alert to be processed got: %v", received)
+	}
+
+	// Alertmanager does not retry resolved alerts without ticket
+	body = `{"status": "resolved", "alerts": [
+  {"status": "resolved", "labels": {"alertname": "InstanceDown", "instance": "host01:9100"}, "fingerprint": "c5a5b3a3b9e6f0d1"}
+]}`
+
+	req = httptest.NewRequest(http.MethodPost, "/v1/alertmanager", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+
+	w = httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "no open or new ticket found") {
+		t.Error("\nActual: ", w.Code, w.Body.String(), "\nExpected: ", http.StatusOK)
+	}
+}
+
+func TestNotificationHandler_Key(t *testing.T) {
+	host := notifier.Notification{IcingaHostname: "Host01"}
+	service := notifier.Notification{IcingaHostname: "Host01", IcingaServiceName: "disk"}
+
+	h := &notificationHandler{}
+
+	if h.key(host) == h.key(service) {
+		t.Errorf("Expected different keys for host and service got: %s", h.key(host))
+	}
+
+	// Related tickets require the notifications of a host to be processed one after another
+	h.byHost = true
+
+	if h.key(host) != h.key(service) {
+		t.Errorf("Expected the same key for host and service got: %s %s", h.key(host), h.key(service))
+	}
+}
+
+func TestServeConfig_Env(t *testing.T) {
+	t.Setenv("NOTIFY_ZAMMAD_LISTEN_TOKEN", "secret")
+
+	var c ServeConfig
+
+	check.LoadFromEnv(&c)
+
+	if c.Token != "secret" {
+		t.Errorf("Expected token from the environment got: %s", c.Token)
+	}
+
+	// The token is not required as flag
+	if _, ok := serveCmd.Flags().Lookup("listen-token").Annotations[cobra.BashCompOneRequiredFlag]; ok {
+		t.Error("Expected --listen-token to be optional")
+	}
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/NETWAYS/go-check"
+	"github.com/spf13/cobra"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+	"github.com/NETWAYS/notify_zammad/internal/client"
+	"github.com/NETWAYS/notify_zammad/internal/icinga"
+)
+
+// SyncConfig holds the configuration for the sync subcommand
+type SyncConfig struct {
+	Action string
+	DryRun bool
+}
+
+const (
+	syncActionClose    = "close"
+	syncActionAnnotate = "annotate"
+	syncActionReport   = "report"
+)
+
+var syncConfig SyncConfig
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Synchronize open Zammad tickets with the current state in Icinga",
+	Long: `Synchronize open Zammad tickets with the current state in Icinga
+
+All new or open tickets managed by the plugin are checked against the Icinga 2 API.
+Tickets whose host or service is OK again, or no longer exists, are
+closed, annotated or reported, depending on the chosen action.`,
+	Run: runSync,
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	fs := syncCmd.Flags()
+	fs.StringVar(&syncConfig.Action, "action", syncActionClose,
+		"Action for tickets whose object is OK or gone (close/annotate/report)")
+	fs.BoolVar(&syncConfig.DryRun, "dry-run", false,
+		"Only report what would be done without changing any tickets")
+
+	fs.SortFlags = false
+}
+
+// staleTicket is a ticket whose Icinga object is no longer in a problem state
+type staleTicket struct {
+	Ticket zammad.Ticket
+	Reason string
+	Output string
+}
+
+func (s staleTicket) String() string {
+	object := s.Ticket.IcingaHost
+
+	if s.Ticket.IcingaService != "" {
+		object += "!" + s.Ticket.IcingaService
+	}
+
+	return fmt.Sprintf("Ticket #%s (%s): %s", s.Ticket.Number, object, s.Reason)
+}
+
+// runSync is the cobra.Command that is executed for the sync subcommand
+func runSync(_ *cobra.Command, _ []string) {
+	switch syncConfig.Action {
+	case syncActionClose, syncActionAnnotate, syncActionReport:
+	default:
+		check.ExitError(fmt.Errorf("unsupported sync action '%s'. Currently supported: close/annotate/report", syncConfig.Action))
+	}
+
+	c := cliConfig.NewClient()
+	ic := cliConfig.NewIcingaClient()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(Timeout)*time.Second)
+	defer cancel()
+
+	tickets, err := c.SearchOpenTickets(ctx)
+
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	// Tickets that could not be looked up are reported, the others are still handled
+	stale, lookupErr := findStaleTickets(ctx, ic, tickets)
+
+	lines := make([]string, 0, len(stale))
+
+	for _, s := range stale {
+		if syncConfig.DryRun || syncConfig.Action == syncActionReport {
+			lines = append(lines, s.String())
+			continue
+		}
+
+		err = resolveStaleTicket(ctx, c, s, syncConfig.Action)
+
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		lines = append(lines, s.String())
+	}
+
+	var summary string
+
+	switch {
+	case syncConfig.DryRun:
+		summary = fmt.Sprintf("%d of %d open tickets would be handled with action %s", len(stale), len(tickets), syncConfig.Action)
+	case syncConfig.Action == syncActionReport:
+		summary = fmt.Sprintf("%d of %d open tickets have no problem in Icinga", len(stale), len(tickets))
+	default:
+		summary = fmt.Sprintf("%d of %d open tickets handled with action %s", len(stale), len(tickets), syncConfig.Action)
+	}
+
+	rc := check.OK
+
+	// Stale tickets that are only reported need the attention of the user
+	if len(stale) > 0 && (syncConfig.DryRun || syncConfig.Action == syncActionReport) {
+		rc = check.Warning
+	}
+
+	if lookupErr != nil {
+		rc = check.Unknown
+		lines = append(lines, strings.Split(lookupErr.Error(), "\n")...)
+	}
+
+	if len(lines) > 0 {
+		summary += "\n" + strings.Join(lines, "\n")
+	}
+
+	check.ExitRaw(rc, summary)
+}
+
+// findStaleTickets returns all tickets whose host or service
+// is OK in Icinga or no longer exists. Tickets that could not be
+// looked up are skipped, their errors are joined.
+func findStaleTickets(ctx context.Context, ic *icinga.Client, tickets []zammad.Ticket) ([]staleTicket, error) {
+	stale := make([]staleTicket, 0)
+
+	var errs []error
+
+	for _, ticket := range tickets {
+		state, err := ic.GetObjectState(ctx, ticket.IcingaHost, ticket.IcingaService)
+
+		if errors.Is(err, icinga.ErrObjectNotFound) {
+			stale = append(stale, staleTicket{Ticket: ticket, Reason: "object no longer exists in Icinga"})
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("ticket #%s: %w", ticket.Number, err))
+			continue
+		}
+
+		if state.OK() {
+			stale = append(stale, staleTicket{Ticket: ticket, Reason: "object is OK in Icinga", Output: state.LastCheck.Output})
+		}
+	}
+
+	return stale, errors.Join(errs...)
+}
+
+// resolveStaleTicket adds an article explaining why the ticket is stale
+// and closes the ticket if requested
+func resolveStaleTicket(ctx context.Context, c client.TicketService, s staleTicket, action string) error {
+	var b strings.Builder
+
+	b.WriteString("<h3>Sync</h3>")
+	b.WriteString(fmt.Sprintf("<p>The %s.</p>", s.Reason))
+
+	if s.Output != "" {
+		b.WriteString(fmt.Sprintf("<p>Check Output: %s</p>", s.Output))
+	}
+
+	a := zammad.Article{
+		TicketID:    s.Ticket.ID,
+		Subject:     "Sync",
+		Body:        b.String(),
+		ContentType: "text/html",
+		Type:        "web",
+		Internal:    true,
+		Sender:      "Agent",
+	}
+
+	err := c.AddArticleToTicket(ctx, a)
+
+	if err != nil {
+		return err
+	}
+
+	if action != syncActionClose {
+		return nil
+	}
+
+	return c.UpdateTicketState(ctx, s.Ticket, zammad.ClosedTicketState)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+	"github.com/NETWAYS/notify_zammad/internal/client"
+	"github.com/NETWAYS/notify_zammad/internal/icinga"
+)
+
+func TestFindStaleTickets(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/objects/hosts/UpHost":
+			w.Write([]byte(`{"results":[{"attrs":{"state":0.0,"last_check_result":{"output":"PING OK"}}}]}`))
+		case "/v1/objects/services/DownHost!MyService":
+			w.Write([]byte(`{"results":[{"attrs":{"state":2.0,"last_check_result":{"output":"CRITICAL"}}}]}`))
+		case "/v1/objects/hosts/BrokenHost":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	ic := icinga.NewClient(*u, http.DefaultTransport)
+
+	tickets := []zammad.Ticket{
+		{ID: 1, Number: "1001", IcingaHost: "UpHost"},
+		{ID: 2, Number: "1002", IcingaHost: "DownHost", IcingaService: "MyService"},
+		{ID: 3, Number: "1003", IcingaHost: "BrokenHost"},
+		{ID: 4, Number: "1004", IcingaHost: "GoneHost", IcingaService: "MyService"},
+	}
+
+	stale, err := findStaleTickets(context.Background(), ic, tickets)
+
+	// A failed lookup does not prevent the other tickets from being checked
+	if err == nil || !strings.Contains(err.Error(), "ticket #1003") {
+		t.Errorf("Expected lookup error of the ticket got: %v", err)
+	}
+
+	if len(stale) != 2 {
+		t.Fatalf("Expected two stale tickets got: %v", stale)
+	}
+
+	expected := "Ticket #1001 (UpHost): object is OK in Icinga"
+	if stale[0].String() != expected {
+		t.Error("\nActual: ", stale[0].String(), "\nExpected: ", expected)
+	}
+
+	expected = "Ticket #1004 (GoneHost!MyService): object no longer exists in Icinga"
+	if stale[1].String() != expected {
+		t.Error("\nActual: ", stale[1].String(), "\nExpected: ", expected)
+	}
+}
+
+func TestResolveStaleTicket(t *testing.T) {
+	var requests []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(b))
+
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+
+		w.Write([]byte(`{}`))
+	}))
+
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	c := client.NewClient(*u, http.DefaultTransport)
+
+	s := staleTicket{
+		Ticket: zammad.Ticket{ID: 13, Number: "65012", IcingaHost: "MyHost"},
+		Reason: "object is OK in Icinga",
+		Output: "PING OK",
+	}
+
+	err := resolveStaleTicket(context.Background(), c, s, syncActionAnnotate)
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	if len(requests) != 1 || !strings.Contains(requests[0], "PING OK") {
+		t.Errorf("Expected only an article to be added got: %v", requests)
+	}
+
+	requests = nil
+
+	err = resolveStaleTicket(context.Background(), c, s, syncActionClose)
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	if len(requests) != 2 || !strings.HasPrefix(requests[1], "PUT /api/v1/tickets/13") {
+		t.Errorf("Expected article and state update got: %v", requests)
+	}
+}
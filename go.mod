@@ -7,9 +7,7 @@ require (
 	github.com/NETWAYS/go-check-network/http v0.0.0-20251202001729-25880c6d17f3
 	github.com/NETWAYS/go-icingadsl v0.1.2
 	github.com/spf13/cobra v1.10.2
+	github.com/spf13/pflag v1.0.10
 )
 
-require (
-	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/spf13/pflag v1.0.10 // indirect
-)
+require github.com/inconshreveable/mousetrap v1.1.0 // indirect
@@ -0,0 +1,63 @@
+package alertmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+)
+
+// Message represents the payload Alertmanager sends to webhook receivers
+// https://prometheus.io/docs/alerting/latest/configuration/#webhook_config
+type Message struct {
+	Version           string            `json:"version"`
+	GroupKey          string            `json:"groupKey"`
+	TruncatedAlerts   int               `json:"truncatedAlerts"`
+	Status            string            `json:"status"`
+	Receiver          string            `json:"receiver"`
+	GroupLabels       map[string]string `json:"groupLabels"`
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	ExternalURL       string            `json:"externalURL"`
+	Alerts            []Alert           `json:"alerts"`
+}
+
+// Alert represents a single alert of a webhook message
+type Alert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// Firing reports if the alert is currently firing
+func (a Alert) Firing() bool {
+	return a.Status == StatusFiring
+}
+
+// Decode reads a webhook message from the given reader
+func Decode(r io.Reader) (Message, error) {
+	var m Message
+
+	err := json.NewDecoder(r).Decode(&m)
+
+	if err != nil {
+		return m, fmt.Errorf("unable to parse Alertmanager message: %w", err)
+	}
+
+	for _, a := range m.Alerts {
+		if a.Status != StatusFiring && a.Status != StatusResolved {
+			return m, fmt.Errorf("unsupported alert status '%s'", a.Status)
+		}
+	}
+
+	return m, nil
+}
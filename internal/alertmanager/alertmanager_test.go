@@ -0,0 +1,72 @@
+package alertmanager
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	payload := `{
+  "version": "4",
+  "groupKey": "{}:{alertname=\"InstanceDown\"}",
+  "truncatedAlerts": 0,
+  "status": "firing",
+  "receiver": "zammad",
+  "groupLabels": {"alertname": "InstanceDown"},
+  "commonLabels": {"alertname": "InstanceDown", "job": "node"},
+  "commonAnnotations": {},
+  "externalURL": "http://alertmanager.example:9093",
+  "alerts": [
+    {
+      "status": "firing",
+      "labels": {"alertname": "InstanceDown", "instance": "host01:9100", "severity": "critical"},
+      "annotations": {"summary": "Instance host01:9100 down"},
+      "startsAt": "2025-05-05T09:38:25.350Z",
+      "endsAt": "0001-01-01T00:00:00Z",
+      "generatorURL": "http://prometheus.example:9090/graph",
+      "fingerprint": "c5a5b3a3b9e6f0d1"
+    },
+    {
+      "status": "resolved",
+      "labels": {"alertname": "InstanceDown", "instance": "host02:9100"},
+      "annotations": {},
+      "startsAt": "2025-05-05T09:38:25.350Z",
+      "endsAt": "2025-05-05T09:48:25.350Z",
+      "generatorURL": "http://prometheus.example:9090/graph",
+      "fingerprint": "a1b2c3d4e5f60718"
+    }
+  ]
+}`
+
+	m, err := Decode(strings.NewReader(payload))
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if len(m.Alerts) != 2 {
+		t.Fatalf("Expected two alerts got: %v", m.Alerts)
+	}
+
+	if !m.Alerts[0].Firing() || m.Alerts[1].Firing() {
+		t.Errorf("Expected first alert to be firing and second to be resolved got: %v", m.Alerts)
+	}
+
+	if m.Alerts[0].Labels["instance"] != "host01:9100" {
+		t.Errorf("Expected instance label got: %v", m.Alerts[0].Labels)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	_, err := Decode(strings.NewReader(`{"alerts": [{"status": "pending"}]}`))
+
+	if err == nil || !strings.Contains(err.Error(), "unsupported alert status") {
+		t.Errorf("Expected status error got: %v", err)
+	}
+
+	_, err = Decode(strings.NewReader(`{"alerts": `))
+
+	if err == nil {
+		t.Error("Expected parse error")
+	}
+}
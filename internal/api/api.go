@@ -1,5 +1,10 @@
 package zammad
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type TicketState string
 
 const (
@@ -21,21 +26,96 @@ type TicketSearchResult struct {
 type NewTicket struct {
 	ID            int     `json:"id,omitempty"`
 	Title         string  `json:"title"`
-	Group         string  `json:"group"`
-	Customer      string  `json:"customer"`
+	Group         string  `json:"group,omitempty"`
+	Customer      string  `json:"customer,omitempty"`
 	IcingaHost    string  `json:"icinga_host"`
 	IcingaService string  `json:"icinga_service"`
 	Article       Article `json:"article,omitempty"`
+
+	// Owner and priority are referenced by their name
+	Owner    string `json:"owner,omitempty"`
+	Priority string `json:"priority,omitempty"`
+
+	// The IDs are used instead of the names if the group, customer or owner was resolved
+	GroupID    int `json:"group_id,omitempty"`
+	CustomerID int `json:"customer_id,omitempty"`
+	OwnerID    int `json:"owner_id,omitempty"`
+
+	// The organization is optional, by default Zammad uses the customer's organization
+	Organization   string `json:"organization,omitempty"`
+	OrganizationID int    `json:"organization_id,omitempty"`
+
+	// Attributes are additional object manager attributes of the ticket, e.g. icinga_zone.
+	// The fields of the struct take precedence over attributes with the same name.
+	Attributes map[string]any `json:"-"`
+}
+
+// MarshalJSON adds the attributes to the fields of the ticket
+func (t NewTicket) MarshalJSON() ([]byte, error) {
+	// The alias prevents the recursion into this method
+	type newTicket NewTicket
+
+	b, err := json.Marshal(newTicket(t))
+
+	if err != nil || len(t.Attributes) == 0 {
+		return b, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+
+	err = json.Unmarshal(b, &fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range t.Attributes {
+		if _, ok := fields[k]; ok {
+			continue
+		}
+
+		value, err := json.Marshal(v)
+
+		if err != nil {
+			return nil, err
+		}
+
+		fields[k] = value
+	}
+
+	return json.Marshal(fields)
 }
 
 type Ticket struct {
 	ID            int    `json:"id,omitempty"`
+	Number        string `json:"number,omitempty"`
 	Title         string `json:"title"`
 	GroupID       int    `json:"group_id"`
 	CustomerID    int    `json:"customer_id"`
+	OwnerID       int    `json:"owner_id,omitempty"`
 	IcingaHost    string `json:"icinga_host"`
 	IcingaService string `json:"icinga_service"`
 	ArticleIDs    []int  `json:"article_ids,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+
+	// Attributes holds all fields of the ticket returned by the API,
+	// including the object manager attributes not covered by the struct
+	Attributes map[string]any `json:"-"`
+}
+
+// UnmarshalJSON keeps all fields of the ticket in the attributes
+func (t *Ticket) UnmarshalJSON(b []byte) error {
+	// The alias prevents the recursion into this method
+	type ticket Ticket
+
+	err := json.Unmarshal(b, (*ticket)(t))
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, &t.Attributes)
 }
 
 // Article represents a Zammad Ticket Article
@@ -48,4 +128,58 @@ type Article struct {
 	Type        string `json:"type"`                // "phone"
 	Sender      string `json:"sender"`              // "Agent"
 	TimeUnit    string `json:"time_unit,omitempty"` // "15"
+
+	// To is the recipient of email articles
+	To string `json:"to,omitempty"`
+}
+
+// LinkType is the type of a link between two tickets
+type LinkType string
+
+const (
+	NormalLinkType LinkType = "normal"
+	ParentLinkType LinkType = "parent"
+	ChildLinkType  LinkType = "child"
+)
+
+// Link represents a link between two Zammad tickets,
+// the source is referenced by its number and the target by its ID
+type Link struct {
+	LinkType               LinkType `json:"link_type"`
+	LinkObjectSource       string   `json:"link_object_source"`
+	LinkObjectSourceNumber string   `json:"link_object_source_number"`
+	LinkObjectTarget       string   `json:"link_object_target"`
+	LinkObjectTargetValue  int      `json:"link_object_target_value"`
+}
+
+// Tag represents a tag added to a Zammad object
+type Tag struct {
+	Object   string `json:"object"`
+	ObjectID int    `json:"o_id"`
+	Item     string `json:"item"`
+}
+
+// User represents a Zammad User
+type User struct {
+	ID        int      `json:"id,omitempty"`
+	Login     string   `json:"login,omitempty"`
+	Email     string   `json:"email,omitempty"`
+	Firstname string   `json:"firstname,omitempty"`
+	Lastname  string   `json:"lastname,omitempty"`
+	Active    bool     `json:"active"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+// Organization represents a Zammad Organization
+type Organization struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
+}
+
+// Group represents a Zammad Group
+type Group struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Active bool   `json:"active"`
 }
@@ -0,0 +1,187 @@
+// Package assign picks the owner of new tickets from a list of agents
+package assign
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+)
+
+const (
+	RoundRobinStrategy = "round-robin"
+	LeastOpenStrategy  = "least-open"
+)
+
+// errNoAgents is returned if a strategy has no agents to choose from
+var errNoAgents = errors.New("no agents configured for the assignment")
+
+// UserResolver resolves the references to agents to their user IDs
+type UserResolver interface {
+	Owner(ctx context.Context, ref string) (int, error)
+}
+
+// TicketSearcher searches the tickets managed by the plugin owned by an agent
+type TicketSearcher interface {
+	SearchOwnedTickets(ctx context.Context, ownerID int) ([]zammad.Ticket, error)
+}
+
+// RoundRobin assigns the tickets to the agents in turn.
+// Since the plugin runs as a new process for each notification,
+// the position is stored in a state file, which is locked while it is updated.
+// The position is advanced before the ticket is created, so concurrent processes
+// don't wait for each other's ticket creation, thus a failed creation skips the agent.
+type RoundRobin struct {
+	Agents []string
+	// StateFile stores the position, without it the position is only kept in memory
+	StateFile string
+	// DryRun reads the state file without advancing the stored position
+	DryRun bool
+
+	mu   sync.Mutex
+	next int
+}
+
+// roundRobinState is stored in the state file
+type roundRobinState struct {
+	Next int `json:"next"`
+}
+
+// Next returns the agent whose turn it is and advances the position
+func (r *RoundRobin) Next(_ context.Context) (string, error) {
+	if len(r.Agents) == 0 {
+		return "", errNoAgents
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	state := roundRobinState{Next: r.next}
+
+	// Concurrent processes must not read the same position
+	if r.StateFile != "" && !r.DryRun {
+		err := os.MkdirAll(filepath.Dir(r.StateFile), 0o700)
+
+		if err != nil {
+			return "", fmt.Errorf("could not create state directory: %w", err)
+		}
+
+		unlock, err := lockFile(r.StateFile + ".lock")
+
+		if err != nil {
+			return "", err
+		}
+
+		defer unlock()
+	}
+
+	// A missing or broken state file starts with the first agent
+	if r.StateFile != "" {
+		if b, err := os.ReadFile(r.StateFile); err == nil {
+			_ = json.Unmarshal(b, &state)
+		}
+	}
+
+	// The list of agents might have changed since the state was written
+	current := state.Next % len(r.Agents)
+
+	if current < 0 {
+		current = 0
+	}
+
+	r.next = (current + 1) % len(r.Agents)
+
+	if r.StateFile != "" && !r.DryRun {
+		err := writeState(r.StateFile, roundRobinState{Next: r.next})
+
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return r.Agents[current], nil
+}
+
+// writeState replaces the state file, the temporary file ensures
+// other processes never read a partially written file
+func writeState(path string, state roundRobinState) error {
+	b, err := json.Marshal(state)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+
+	if err != nil {
+		return fmt.Errorf("could not create state directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+
+	if err != nil {
+		return fmt.Errorf("could not write assignment state: %w", err)
+	}
+
+	_, err = f.Write(b)
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("could not write assignment state: %w", err)
+	}
+
+	return nil
+}
+
+// LeastOpen assigns the tickets to the agent with the fewest
+// new or open tickets managed by the plugin.
+// If several agents have the same number of tickets, the first one is used.
+type LeastOpen struct {
+	Agents   []string
+	Users    UserResolver
+	Searcher TicketSearcher
+}
+
+// Next returns the agent with the fewest open tickets
+func (l *LeastOpen) Next(ctx context.Context) (string, error) {
+	if len(l.Agents) == 0 {
+		return "", errNoAgents
+	}
+
+	agent := ""
+	fewest := -1
+
+	for _, a := range l.Agents {
+		id, err := l.Users.Owner(ctx, a)
+
+		if err != nil {
+			return "", err
+		}
+
+		tickets, err := l.Searcher.SearchOwnedTickets(ctx, id)
+
+		if err != nil {
+			return "", err
+		}
+
+		if fewest < 0 || len(tickets) < fewest {
+			agent = a
+			fewest = len(tickets)
+		}
+	}
+
+	return agent, nil
+}
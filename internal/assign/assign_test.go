@@ -0,0 +1,182 @@
+package assign
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/NETWAYS/notify_zammad/internal/client"
+	"github.com/NETWAYS/notify_zammad/internal/resolve"
+	"github.com/NETWAYS/notify_zammad/internal/zammadtest"
+)
+
+func TestRoundRobin(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "state", "round-robin.json")
+
+	agents := []string{"jon.snow", "arya.stark", "sansa.stark"}
+
+	var picked []string
+
+	// Each notification is handled by a new process
+	for range 4 {
+		r := &RoundRobin{Agents: agents, StateFile: stateFile}
+
+		agent, err := r.Next(context.Background())
+
+		if err != nil {
+			t.Fatalf("Did not expect error: %v", err)
+		}
+
+		picked = append(picked, agent)
+	}
+
+	if strings.Join(picked, ",") != "jon.snow,arya.stark,sansa.stark,jon.snow" {
+		t.Errorf("Expected agents in turn got: %v", picked)
+	}
+
+	// A shorter list of agents starts over
+	r := &RoundRobin{Agents: agents[:1], StateFile: stateFile}
+
+	if agent, _ := r.Next(context.Background()); agent != "jon.snow" {
+		t.Errorf("Expected first agent got: %s", agent)
+	}
+}
+
+func TestRoundRobin_Concurrent(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "round-robin.json")
+
+	agents := []string{"jon.snow", "arya.stark", "sansa.stark", "bran.stark"}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		picked = map[string]int{}
+	)
+
+	// Processes handling notifications at the same time pick different agents
+	for range 8 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			r := &RoundRobin{Agents: agents, StateFile: stateFile}
+
+			agent, err := r.Next(context.Background())
+
+			if err != nil {
+				t.Errorf("Did not expect error: %v", err)
+			}
+
+			mu.Lock()
+			picked[agent]++
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	for _, agent := range agents {
+		if picked[agent] != 2 {
+			t.Errorf("Expected each agent twice got: %v", picked)
+			break
+		}
+	}
+}
+
+func TestRoundRobin_DryRun(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "round-robin.json")
+
+	agents := []string{"jon.snow", "arya.stark"}
+
+	for range 2 {
+		r := &RoundRobin{Agents: agents, StateFile: stateFile, DryRun: true}
+
+		if agent, _ := r.Next(context.Background()); agent != "jon.snow" {
+			t.Errorf("Expected first agent got: %s", agent)
+		}
+	}
+
+	if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+		t.Errorf("Expected no state file in dry run got: %v", err)
+	}
+}
+
+func TestRoundRobin_InMemory(t *testing.T) {
+	r := &RoundRobin{Agents: []string{"jon.snow", "arya.stark"}}
+
+	var picked []string
+
+	for range 3 {
+		agent, _ := r.Next(context.Background())
+		picked = append(picked, agent)
+	}
+
+	if strings.Join(picked, ",") != "jon.snow,arya.stark,jon.snow" {
+		t.Errorf("Expected agents in turn got: %v", picked)
+	}
+}
+
+func TestRoundRobin_BrokenState(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "round-robin.json")
+
+	err := os.WriteFile(stateFile, []byte("{"), 0o600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &RoundRobin{Agents: []string{"jon.snow", "arya.stark"}, StateFile: stateFile}
+
+	if agent, _ := r.Next(context.Background()); agent != "jon.snow" {
+		t.Errorf("Expected first agent got: %s", agent)
+	}
+
+	if _, err := (&RoundRobin{}).Next(context.Background()); err == nil {
+		t.Error("Expected error without agents")
+	}
+}
+
+func TestLeastOpen(t *testing.T) {
+	s := zammadtest.NewServer()
+	defer s.Close()
+
+	jon := s.AddUser(zammadtest.User{"login": "jon.snow"})
+	arya := s.AddUser(zammadtest.User{"login": "arya.stark"})
+
+	s.AddTicket(zammadtest.Ticket{"icinga_host": "Host01", "owner_id": jon["id"]})
+	s.AddTicket(zammadtest.Ticket{"icinga_host": "Host02", "owner_id": jon["id"]})
+	s.AddTicket(zammadtest.Ticket{"icinga_host": "Host03", "owner_id": arya["id"]})
+	// Closed tickets and tickets not managed by the plugin are not counted
+	s.AddTicket(zammadtest.Ticket{"icinga_host": "Host04", "owner_id": jon["id"], "state": "closed"})
+	s.AddTicket(zammadtest.Ticket{"title": "Printer broken", "owner_id": arya["id"]})
+	s.AddTicket(zammadtest.Ticket{"title": "Printer still broken", "owner_id": arya["id"]})
+
+	u, _ := url.Parse(s.URL)
+	c := client.NewClient(*u, http.DefaultTransport)
+
+	l := &LeastOpen{Agents: []string{"jon.snow", "arya.stark"}, Users: resolve.New(c), Searcher: c}
+
+	agent, err := l.Next(context.Background())
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if agent != "arya.stark" {
+		t.Errorf("Expected agent with fewest open tickets got: %s", agent)
+	}
+
+	l.Agents = []string{"jon.snow", "bran.stark"}
+
+	_, err = l.Next(context.Background())
+
+	if err == nil || !strings.Contains(err.Error(), "owner 'bran.stark' not found") {
+		t.Errorf("Expected unknown agent error got: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+//go:build !unix
+
+package assign
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// staleLock is the age after which a lock file is considered left behind by a crashed process
+const staleLock = time.Minute
+
+// lockFile creates the file exclusively, it waits until
+// the file is removed by other processes
+func lockFile(path string) (func(), error) {
+	deadline := time.Now().Add(10 * time.Second)
+
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+
+		if err == nil {
+			_ = f.Close()
+
+			return func() { _ = os.Remove(path) }, nil
+		}
+
+		if !errors.Is(err, os.ErrExist) || time.Now().After(deadline) {
+			return nil, fmt.Errorf("could not lock assignment state: %w", err)
+		}
+
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > staleLock {
+			_ = os.Remove(path)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
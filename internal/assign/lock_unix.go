@@ -0,0 +1,31 @@
+//go:build unix
+
+package assign
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on the file, it blocks until
+// the lock is released by other processes
+func lockFile(path string) (func(), error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o600)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not lock assignment state: %w", err)
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("could not lock assignment state: %w", err)
+	}
+
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		_ = f.Close()
+	}, nil
+}
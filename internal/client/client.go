@@ -1,12 +1,10 @@
 package client
 
-// TODO Currently contains some duplicated code that could be
-// DRY refactored later.
-
 import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,12 +15,29 @@ import (
 	zammad "github.com/NETWAYS/notify_zammad/internal/api"
 )
 
+// ErrNotFound is returned if the requested object does not exist
+var ErrNotFound = errors.New("object not found")
+
 type Client struct {
-	Client  http.Client
-	URL     url.URL
+	Client http.Client
+	URL    url.URL
+	// Headers are sent with every request
 	Headers http.Header
 }
 
+// OnBehalfOfHeader makes Zammad perform the request as the given user,
+// the API user needs the admin.user permission for that
+const OnBehalfOfHeader = "X-On-Behalf-Of"
+
+type onBehalfOfKey struct{}
+
+// WithOnBehalfOf returns a context for requests performed on behalf of the given
+// Zammad user, which is referenced by login, email or ID.
+// It takes precedence over the X-On-Behalf-Of header in the client's Headers.
+func WithOnBehalfOf(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, onBehalfOfKey{}, user)
+}
+
 func NewClient(url url.URL, rt http.RoundTripper) *Client {
 	// Small wrapper for the http.Client that we feed with a custom RoundTripper
 	c := &http.Client{
@@ -30,9 +45,29 @@ func NewClient(url url.URL, rt http.RoundTripper) *Client {
 	}
 
 	return &Client{
-		URL:    url,
-		Client: *c,
+		URL:     url,
+		Client:  *c,
+		Headers: make(http.Header),
+	}
+}
+
+// newRequest creates a request with the client's headers
+func (c *Client) newRequest(ctx context.Context, method, u string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create request: %w", err)
+	}
+
+	for k, v := range c.Headers {
+		req.Header[k] = v
+	}
+
+	if user, ok := ctx.Value(onBehalfOfKey{}).(string); ok && user != "" {
+		req.Header.Set(OnBehalfOfHeader, user)
 	}
+
+	return req, nil
 }
 
 // SearchTickets searches tickets for the given hostname and service.
@@ -41,6 +76,90 @@ func NewClient(url url.URL, rt http.RoundTripper) *Client {
 func (c *Client) SearchTickets(ctx context.Context, hostname, service string) ([]zammad.Ticket, error) {
 	query := fmt.Sprintf("icinga_host: %s AND (state.name: new OR state.name: open)", hostname)
 
+	result, err := c.searchTickets(ctx, query, 0, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// We only care about the tickets, thus we create a slice to easier work with them
+	tickets := make([]zammad.Ticket, 0, len(result))
+
+	for _, ticket := range result {
+		// If no service is provided we add the ticket and are done
+		if service == "" {
+			tickets = append(tickets, ticket)
+			continue
+		}
+
+		// If a service is provided and it is matching the ticket's service
+		if service != "" && ticket.IcingaService == service {
+			tickets = append(tickets, ticket)
+		}
+	}
+
+	return tickets, nil
+}
+
+// SearchOpenTickets returns all new or open tickets managed by the plugin,
+// meaning all tickets that have the icinga_host field set.
+// The results are fetched page by page.
+func (c *Client) SearchOpenTickets(ctx context.Context) ([]zammad.Ticket, error) {
+	return c.searchManagedTickets(ctx, "icinga_host: * AND (state.name: new OR state.name: open)")
+}
+
+// SearchOwnedTickets returns all new or open tickets managed by the plugin
+// that are owned by the given user
+func (c *Client) SearchOwnedTickets(ctx context.Context, ownerID int) ([]zammad.Ticket, error) {
+	return c.searchManagedTickets(ctx, fmt.Sprintf("icinga_host: * AND (state.name: new OR state.name: open) AND owner_id: %d", ownerID))
+}
+
+// searchManagedTickets returns the tickets managed by the plugin matching the query,
+// the results are fetched page by page
+func (c *Client) searchManagedTickets(ctx context.Context, query string) ([]zammad.Ticket, error) {
+	tickets := make([]zammad.Ticket, 0)
+	seen := make(map[int]bool)
+
+	for page := 1; ; page++ {
+		result, err := c.searchTickets(ctx, query, page, searchPageSize)
+
+		if err != nil {
+			return nil, err
+		}
+
+		found := false
+
+		for _, ticket := range result {
+			if seen[ticket.ID] {
+				continue
+			}
+
+			seen[ticket.ID] = true
+			found = true
+
+			// Tickets without a host are not managed by the plugin
+			if ticket.IcingaHost != "" {
+				tickets = append(tickets, ticket)
+			}
+		}
+
+		// Zammad might limit the page size below the requested one, thus only
+		// a page without new tickets marks the end, e.g. if the page is ignored
+		if !found {
+			break
+		}
+	}
+
+	return tickets, nil
+}
+
+// searchPageSize is the maximum number of tickets per page
+// the Zammad search API returns
+const searchPageSize = 200
+
+// searchTickets runs the given query against the Zammad search API.
+// If page is 0 no pagination parameters are sent.
+func (c *Client) searchTickets(ctx context.Context, query string, page, perPage int) ([]zammad.Ticket, error) {
 	u := c.URL.JoinPath("/api/v1/tickets/search")
 
 	// Add ?search URL parameter with the given query
@@ -51,12 +170,18 @@ func (c *Client) SearchTickets(ctx context.Context, hostname, service string) ([
 	// This will return the newest ticket first
 	search.Set("sort_by", "created_at")
 	search.Set("order_by", "desc")
+
+	if page > 0 {
+		search.Set("page", strconv.Itoa(page))
+		search.Set("per_page", strconv.Itoa(perPage))
+	}
+
 	u.RawQuery = search.Encode()
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	req, err := c.newRequest(ctx, http.MethodGet, u.String(), nil)
 
 	if err != nil {
-		return nil, fmt.Errorf("could not create request: %w", err)
+		return nil, err
 	}
 
 	resp, err := c.Client.Do(req)
@@ -92,123 +217,291 @@ func (c *Client) SearchTickets(ctx context.Context, hostname, service string) ([
 		return nil, fmt.Errorf("unable to parse search results: %w", err)
 	}
 
-	// We only care about the tickets, thus we create a slice to easier work with them
-	tickets := make([]zammad.Ticket, 0, len(result))
+	return result, nil
+}
 
-	for _, ticket := range result {
-		// If no service is provided we add the ticket and are done
-		if service == "" {
-			tickets = append(tickets, ticket)
-			continue
-		}
+// AddArticleToTicket adds an article to an existing ticket
+func (c *Client) AddArticleToTicket(ctx context.Context, article zammad.Article) error {
+	_, err := c.send(ctx, http.MethodPost, c.URL.JoinPath("/api/v1/ticket_articles"), article, http.StatusCreated, "add article")
 
-		// If a service is provided and it is matching the ticket's service
-		if service != "" && ticket.IcingaService == service {
-			tickets = append(tickets, ticket)
-		}
+	return err
+}
+
+// CreateTicket create a new ticket in Zammad
+// and returns the ticket created by the API
+func (c *Client) CreateTicket(ctx context.Context, ticket zammad.NewTicket) (zammad.Ticket, error) {
+	var created zammad.Ticket
+
+	b, err := c.send(ctx, http.MethodPost, c.URL.JoinPath("/api/v1/tickets"), ticket, http.StatusCreated, "create ticket")
+
+	if err != nil {
+		return created, err
 	}
 
-	return tickets, nil
+	err = json.Unmarshal(b, &created)
+
+	if err != nil {
+		return created, fmt.Errorf("unable to parse created ticket: %w", err)
+	}
+
+	return created, nil
 }
 
-// AddArticleToTicket adds an article to an existing ticket
-func (c *Client) AddArticleToTicket(ctx context.Context, article zammad.Article) error {
-	url := c.URL.JoinPath("/api/v1/ticket_articles")
+// TicketURL returns the URL of the given ticket in the Zammad web interface
+func (c *Client) TicketURL(ticket zammad.Ticket) string {
+	return strings.TrimSuffix(c.URL.String(), "/") + "/#ticket/zoom/" + strconv.Itoa(ticket.ID)
+}
+
+// UpdateTicket updates the given fields of a ticket
+// and returns the ticket updated by the API
+func (c *Client) UpdateTicket(ctx context.Context, ticketID int, fields map[string]any) (zammad.Ticket, error) {
+	var updated zammad.Ticket
 
-	data, err := json.Marshal(article)
+	b, err := c.send(ctx, http.MethodPut, c.URL.JoinPath("/api/v1/tickets", strconv.Itoa(ticketID)), fields, http.StatusOK, "update ticket")
 
 	if err != nil {
-		return err
+		return updated, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url.String(), bytes.NewBuffer(data))
-	req.Header.Set("Content-Type", "application/json")
+	err = json.Unmarshal(b, &updated)
 
 	if err != nil {
-		return fmt.Errorf("could not create request: %w", err)
+		return updated, fmt.Errorf("unable to parse updated ticket: %w", err)
 	}
 
-	resp, err := c.Client.Do(req)
+	return updated, nil
+}
 
-	// Retrieve response body since to have details on potential errors
-	b, _ := io.ReadAll(resp.Body)
+// UpdateTicketState updates the ticket to the given state
+func (c *Client) UpdateTicketState(ctx context.Context, ticket zammad.Ticket, state zammad.TicketState) error {
+	_, err := c.UpdateTicket(ctx, ticket.ID, map[string]any{"state": state})
 
-	if err != nil {
-		return fmt.Errorf("could not add article: %w - Error: %s", err, string(b))
+	return err
+}
+
+// AddTag adds a tag to the given ticket
+func (c *Client) AddTag(ctx context.Context, ticketID int, tag string) error {
+	t := zammad.Tag{
+		Object:   "Ticket",
+		ObjectID: ticketID,
+		Item:     tag,
 	}
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("could not add article: %s - Error: %s", url.String(), string(b))
+	_, err := c.send(ctx, http.MethodPost, c.URL.JoinPath("/api/v1/tags/add"), t, http.StatusCreated, "add tag")
+
+	return err
+}
+
+// LinkTickets links the source ticket to the target ticket with the given link type
+func (c *Client) LinkTickets(ctx context.Context, source, target zammad.Ticket, linkType zammad.LinkType) error {
+	l := zammad.Link{
+		LinkType:               linkType,
+		LinkObjectSource:       "Ticket",
+		LinkObjectSourceNumber: source.Number,
+		LinkObjectTarget:       "Ticket",
+		LinkObjectTargetValue:  target.ID,
 	}
 
-	defer resp.Body.Close()
+	_, err := c.send(ctx, http.MethodPost, c.URL.JoinPath("/api/v1/links/add"), l, http.StatusCreated, "link tickets")
 
 	return err
 }
 
-// CreateTicket create a new ticket in Zammad
-func (c *Client) CreateTicket(ctx context.Context, ticket zammad.NewTicket) error {
-	url := c.URL.JoinPath("/api/v1/tickets")
+// SearchUsers searches users by the given query,
+// the result contains all users with a partially matching login, email or name
+func (c *Client) SearchUsers(ctx context.Context, query string) ([]zammad.User, error) {
+	var users []zammad.User
+
+	u := c.URL.JoinPath("/api/v1/users/search")
+
+	search := u.Query()
+	search.Set("query", query)
+	u.RawQuery = search.Encode()
 
-	data, err := json.Marshal(ticket)
+	b, err := c.send(ctx, http.MethodGet, u, nil, http.StatusOK, "search for users")
 
 	if err != nil {
-		return err
+		return users, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url.String(), bytes.NewBuffer(data))
-	req.Header.Set("Content-Type", "application/json")
+	err = json.Unmarshal(b, &users)
 
 	if err != nil {
-		return fmt.Errorf("could not create request: %w", err)
+		return users, fmt.Errorf("unable to parse users: %w", err)
 	}
 
-	resp, err := c.Client.Do(req)
+	return users, nil
+}
 
-	// Retrieve response body since to have details on potential errors
-	b, _ := io.ReadAll(resp.Body)
+// GetUser returns the user with the given ID, ErrNotFound is returned if it does not exist
+func (c *Client) GetUser(ctx context.Context, userID int) (zammad.User, error) {
+	var user zammad.User
+
+	b, err := c.send(ctx, http.MethodGet, c.URL.JoinPath("/api/v1/users", strconv.Itoa(userID)), nil, http.StatusOK, "get user")
 
 	if err != nil {
-		return fmt.Errorf("could not create ticket: %w - Error: %s", err, string(b))
+		return user, err
 	}
 
-	if resp.StatusCode != http.StatusCreated {
-		return fmt.Errorf("could not create ticket: %s - Error: %s", url.String(), string(b))
+	err = json.Unmarshal(b, &user)
+
+	if err != nil {
+		return user, fmt.Errorf("unable to parse user: %w", err)
 	}
 
-	defer resp.Body.Close()
+	return user, nil
+}
 
-	return err
+// CreateUser creates a new user and returns the user created by the API
+func (c *Client) CreateUser(ctx context.Context, user zammad.User) (zammad.User, error) {
+	var created zammad.User
+
+	b, err := c.send(ctx, http.MethodPost, c.URL.JoinPath("/api/v1/users"), user, http.StatusCreated, "create user")
+
+	if err != nil {
+		return created, err
+	}
+
+	err = json.Unmarshal(b, &created)
+
+	if err != nil {
+		return created, fmt.Errorf("unable to parse created user: %w", err)
+	}
+
+	return created, nil
 }
 
-// UpdateTicketState updates the ticket to the given state
-func (c *Client) UpdateTicketState(ctx context.Context, ticket zammad.Ticket, state zammad.TicketState) error {
-	url := c.URL.JoinPath("/api/v1/tickets", strconv.Itoa(ticket.ID))
+// Groups returns all groups
+func (c *Client) Groups(ctx context.Context) ([]zammad.Group, error) {
+	var groups []zammad.Group
+
+	b, err := c.send(ctx, http.MethodGet, c.URL.JoinPath("/api/v1/groups"), nil, http.StatusOK, "list groups")
 
-	// Set the state field with the given state to be sent to the API
-	data := []byte(fmt.Sprintf("{\"state\": \"%s\"}", state))
+	if err != nil {
+		return groups, err
+	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url.String(), bytes.NewBuffer(data))
-	req.Header.Set("Content-Type", "application/json")
+	err = json.Unmarshal(b, &groups)
 
 	if err != nil {
-		return fmt.Errorf("could not create request: %w", err)
+		return groups, fmt.Errorf("unable to parse groups: %w", err)
+	}
+
+	return groups, nil
+}
+
+// GetGroup returns the group with the given ID, ErrNotFound is returned if it does not exist
+func (c *Client) GetGroup(ctx context.Context, groupID int) (zammad.Group, error) {
+	var group zammad.Group
+
+	b, err := c.send(ctx, http.MethodGet, c.URL.JoinPath("/api/v1/groups", strconv.Itoa(groupID)), nil, http.StatusOK, "get group")
+
+	if err != nil {
+		return group, err
+	}
+
+	err = json.Unmarshal(b, &group)
+
+	if err != nil {
+		return group, fmt.Errorf("unable to parse group: %w", err)
+	}
+
+	return group, nil
+}
+
+// SearchOrganizations searches organizations by the given query,
+// the result contains all organizations with a partially matching name
+func (c *Client) SearchOrganizations(ctx context.Context, query string) ([]zammad.Organization, error) {
+	var organizations []zammad.Organization
+
+	u := c.URL.JoinPath("/api/v1/organizations/search")
+
+	search := u.Query()
+	search.Set("query", query)
+	u.RawQuery = search.Encode()
+
+	b, err := c.send(ctx, http.MethodGet, u, nil, http.StatusOK, "search for organizations")
+
+	if err != nil {
+		return organizations, err
+	}
+
+	err = json.Unmarshal(b, &organizations)
+
+	if err != nil {
+		return organizations, fmt.Errorf("unable to parse organizations: %w", err)
+	}
+
+	return organizations, nil
+}
+
+// GetOrganization returns the organization with the given ID, ErrNotFound is returned if it does not exist
+func (c *Client) GetOrganization(ctx context.Context, organizationID int) (zammad.Organization, error) {
+	var organization zammad.Organization
+
+	b, err := c.send(ctx, http.MethodGet, c.URL.JoinPath("/api/v1/organizations", strconv.Itoa(organizationID)), nil, http.StatusOK, "get organization")
+
+	if err != nil {
+		return organization, err
+	}
+
+	err = json.Unmarshal(b, &organization)
+
+	if err != nil {
+		return organization, fmt.Errorf("unable to parse organization: %w", err)
+	}
+
+	return organization, nil
+}
+
+// send sends the payload as JSON to the given URL and returns the response body.
+// An error is returned if the API does not respond with the expected status code.
+func (c *Client) send(ctx context.Context, method string, u *url.URL, payload any, expected int, action string) ([]byte, error) {
+	var body io.Reader
+
+	// Requests without payload, like GET requests, are sent without body
+	if payload != nil {
+		data, err := json.Marshal(payload)
+
+		if err != nil {
+			return nil, err
+		}
+
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := c.newRequest(ctx, method, u.String(), body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
 
 	resp, err := c.Client.Do(req)
 
+	if err != nil {
+		return nil, fmt.Errorf("could not %s: %w", action, err)
+	}
+
+	defer resp.Body.Close()
+
 	// Retrieve response body since to have details on potential errors
 	b, _ := io.ReadAll(resp.Body)
 
-	if err != nil {
-		return fmt.Errorf("could not update ticket: %w - Error: %s", err, string(b))
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("authentication failed for %s", c.URL.String())
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("could not update ticket: %s - Error: %s", url.String(), string(b))
+	if resp.StatusCode == http.StatusNotFound && expected != http.StatusNotFound {
+		return nil, fmt.Errorf("could not %s: %s - %w", action, u.String(), ErrNotFound)
 	}
 
-	defer resp.Body.Close()
+	if resp.StatusCode != expected {
+		return nil, fmt.Errorf("could not %s: %s - Error: %s", action, u.String(), string(b))
+	}
 
-	return err
+	return b, nil
 }
@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+	"github.com/NETWAYS/notify_zammad/internal/zammadtest"
 )
 
 func TestUpdateTicketState(t *testing.T) {
@@ -67,7 +68,7 @@ func TestCreateTicket(t *testing.T) {
 			t.Errorf("Expected new ticket got: %s", string(b))
 		}
 
-		w.Write([]byte(`{}`))
+		w.Write([]byte(`{"id": 13, "number": "65012", "title": "MyNewTicket"}`))
 	}))
 
 	defer ts.Close()
@@ -87,11 +88,28 @@ func TestCreateTicket(t *testing.T) {
 		Title: "MyNewTicket",
 	}
 
-	err := c.CreateTicket(ctx, ticket)
+	created, err := c.CreateTicket(ctx, ticket)
 
 	if err != nil {
 		t.Errorf("Did not except error: %v", err)
 	}
+
+	if created.Number != "65012" {
+		t.Errorf("Expected created ticket number got: %v", created)
+	}
+}
+
+func TestTicketURL(t *testing.T) {
+	u, _ := url.Parse("https://zammad.example/")
+
+	c := NewClient(*u, http.DefaultTransport)
+
+	actual := c.TicketURL(zammad.Ticket{ID: 13})
+	expected := "https://zammad.example/#ticket/zoom/13"
+
+	if actual != expected {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
 }
 
 func TestSearchTickets(t *testing.T) {
@@ -391,3 +409,172 @@ func TestAddArticleToTicket(t *testing.T) {
 		t.Errorf("Did not except error: %v", err)
 	}
 }
+
+func TestSearchOpenTickets(t *testing.T) {
+	var pages []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET request, got %s", r.Method)
+		}
+
+		if !strings.HasPrefix(r.URL.Query().Get("query"), "icinga_host: *") {
+			t.Errorf("Expected query for all plugin tickets, got %s", r.URL.Query().Get("query"))
+		}
+
+		pages = append(pages, r.URL.Query().Get("page"))
+
+		w.WriteHeader(http.StatusOK)
+
+		// Zammad limits the page size, the pages are shorter than requested
+		switch r.URL.Query().Get("page") {
+		case "1":
+			w.Write([]byte(`[
+  {"id": 13, "number": "65012", "icinga_host": "MyHost", "icinga_service": ""},
+  {"id": 14, "number": "65013", "icinga_host": "MyHost", "icinga_service": "MyService"},
+  {"id": 15, "number": "65014", "icinga_host": null, "icinga_service": null}
+]`))
+		case "2":
+			w.Write([]byte(`[{"id": 16, "number": "65015", "icinga_host": "OtherHost", "icinga_service": ""}]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+
+	defer ts.Close()
+
+	rt := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, rt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tickets, err := c.SearchOpenTickets(ctx)
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	if len(tickets) != 3 {
+		t.Errorf("Expected only plugin tickets got: %v", tickets)
+	}
+
+	if strings.Join(pages, ",") != "1,2,3" {
+		t.Errorf("Expected pages until an empty one got: %v", pages)
+	}
+}
+
+func TestSearchOpenTickets_PageIgnored(t *testing.T) {
+	var requests int
+
+	// A proxy might drop the pagination parameters
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		w.Write([]byte(`[
+  {"id": 13, "number": "65012", "icinga_host": "MyHost", "icinga_service": ""},
+  {"id": 14, "number": "65013", "icinga_host": "MyHost", "icinga_service": "MyService"}
+]`))
+	}))
+
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, http.DefaultTransport)
+
+	tickets, err := c.SearchOpenTickets(context.Background())
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	if len(tickets) != 2 || requests != 2 {
+		t.Errorf("Expected the tickets once got: %v (%d requests)", tickets, requests)
+	}
+}
+
+func TestTicketService(t *testing.T) {
+	s := zammadtest.NewServer()
+	defer s.Close()
+
+	u, _ := url.Parse(s.URL)
+
+	c := NewClient(*u, http.DefaultTransport)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	host, err := c.CreateTicket(ctx, zammad.NewTicket{Title: "host", Group: "Users", IcingaHost: "Host01"})
+
+	if err != nil {
+		t.Fatalf("Did not except error: %v", err)
+	}
+
+	service, _ := c.CreateTicket(ctx, zammad.NewTicket{Title: "service", Group: "Users", IcingaHost: "Host01", IcingaService: "disk"})
+
+	updated, err := c.UpdateTicket(ctx, service.ID, map[string]any{"title": "foo", "priority": "3 high"})
+
+	if err != nil || updated.Title != "foo" {
+		t.Errorf("Expected updated ticket got: %v %v", updated, err)
+	}
+
+	err = c.AddTag(ctx, service.ID, "icinga")
+
+	if err != nil || len(s.Tags(service.ID)) != 1 {
+		t.Errorf("Expected tag got: %v %v", s.Tags(service.ID), err)
+	}
+
+	err = c.LinkTickets(ctx, service, host, zammad.ChildLinkType)
+
+	if err != nil {
+		t.Fatalf("Did not except error: %v", err)
+	}
+
+	links := s.Links()
+
+	if len(links) != 1 || links[0].SourceID != service.ID || links[0].TargetID != host.ID || links[0].Type != "child" {
+		t.Errorf("Expected link got: %v", links)
+	}
+
+	_, err = c.UpdateTicket(ctx, 4711, map[string]any{"title": "foo"})
+
+	if err == nil || !strings.Contains(err.Error(), "could not update ticket") {
+		t.Errorf("Expected error got: %v", err)
+	}
+}
+
+func TestOnBehalfOf(t *testing.T) {
+	var received []string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(OnBehalfOfHeader))
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, http.DefaultTransport)
+
+	ctx := context.Background()
+
+	_ = c.AddArticleToTicket(ctx, zammad.Article{TicketID: 1})
+
+	c.Headers.Set(OnBehalfOfHeader, "monitoring")
+
+	_ = c.AddArticleToTicket(ctx, zammad.Article{TicketID: 1})
+	_ = c.AddArticleToTicket(WithOnBehalfOf(ctx, "jdoe"), zammad.Article{TicketID: 1})
+
+	expected := []string{"", "monitoring", "jdoe"}
+
+	if strings.Join(received, ",") != strings.Join(expected, ",") {
+		t.Error("\nActual: ", received, "\nExpected: ", expected)
+	}
+}
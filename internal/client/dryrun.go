@@ -0,0 +1,84 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// dryRunTicket answers the creation of tickets. Its ID is not used by Zammad,
+// so the following requests of the new ticket, e.g. tags and links, are printed as well.
+const dryRunTicket = `{"id": -1, "number": "DRY-RUN"}`
+
+type dryRunRoundTripper struct {
+	w  io.Writer
+	rt http.RoundTripper
+}
+
+// NewDryRunRoundTripper passes read-only requests (GET, HEAD) to the given RoundTripper.
+// All other requests are not sent, instead they are written to w
+// and answered with an empty JSON object, or a placeholder ticket if a ticket is created.
+func NewDryRunRoundTripper(w io.Writer, rt http.RoundTripper) http.RoundTripper {
+	return &dryRunRoundTripper{w, rt}
+}
+
+func (rt *dryRunRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return rt.rt.RoundTrip(req)
+	}
+
+	var body []byte
+
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+
+		_ = req.Body.Close()
+
+		if err != nil {
+			return nil, err
+		}
+
+		body = b
+	}
+
+	// Print JSON payloads indented to make them easier to read
+	var out bytes.Buffer
+
+	if json.Indent(&out, body, "", "  ") != nil {
+		out.Reset()
+		out.Write(body)
+	}
+
+	fmt.Fprintf(rt.w, "Would send %s %s\n%s\n", req.Method, req.URL.String(), out.String())
+
+	status := http.StatusOK
+	resp := "{}"
+
+	if req.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+
+	if req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/api/v1/tickets") {
+		resp = dryRunTicket
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(resp)),
+		Request:    req,
+	}, nil
+}
+
+func (rt *dryRunRoundTripper) CloseIdleConnections() {
+	if ci, ok := rt.rt.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
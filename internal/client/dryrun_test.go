@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+)
+
+func TestDryRunRoundTripper(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected only GET requests to be sent, got %s", r.Method)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[]`))
+	}))
+
+	defer ts.Close()
+
+	var out strings.Builder
+
+	rt := NewDryRunRoundTripper(&out, &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	})
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, rt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, err := c.SearchTickets(ctx, "MyHost", "")
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	created, err := c.CreateTicket(ctx, zammad.NewTicket{Title: "MyNewTicket"})
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	// The requests following the creation refer to the placeholder ticket
+	if created.ID == 0 || created.Number != "DRY-RUN" {
+		t.Errorf("Expected placeholder ticket got: %v", created)
+	}
+
+	err = c.AddTag(ctx, created.ID, "icinga")
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	err = c.LinkTickets(ctx, created, zammad.Ticket{ID: 12, Number: "65011"}, zammad.ChildLinkType)
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	err = c.UpdateTicketState(ctx, zammad.Ticket{ID: 13}, zammad.ClosedTicketState)
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	actual := out.String()
+
+	for _, expected := range []string{
+		"Would send POST " + ts.URL + "/api/v1/tickets\n{\n  \"title\": \"MyNewTicket\",",
+		"Would send PUT " + ts.URL + "/api/v1/tickets/13\n{\n  \"state\": \"closed\"\n}",
+		"Would send POST " + ts.URL + "/api/v1/tags/add",
+		"\"o_id\": -1",
+		"Would send POST " + ts.URL + "/api/v1/links/add",
+		"\"link_object_source_number\": \"DRY-RUN\"",
+	} {
+		if !strings.Contains(actual, expected) {
+			t.Error("\nActual: ", actual, "\nExpected: ", expected)
+		}
+	}
+
+	if strings.Contains(actual, "search") {
+		t.Errorf("Expected search not to be printed got: %s", actual)
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// failoverCooldown is the time a failed endpoint is skipped
+// in favor of the other endpoints
+const failoverCooldown = 30 * time.Second
+
+// Endpoint is a Zammad instance reachable via its own transport
+type Endpoint struct {
+	URL       url.URL
+	Transport http.RoundTripper
+}
+
+// FailoverRoundTripper sends the requests to the first healthy endpoint.
+// If an endpoint fails with a connection error or a 5xx status,
+// the request is sent to the next endpoint and the failed endpoint
+// is skipped for a while.
+//
+// Requests that are not idempotent, like creating tickets or articles, might have been
+// processed by an endpoint that answers with a 5xx status (e.g. a 502 from a proxy).
+// To prevent duplicates, these are only sent to the next endpoint if the connection failed.
+//
+// Requests are expected to be made for the URL of the first endpoint,
+// the base URL is replaced with the URL of the endpoint used.
+type FailoverRoundTripper struct {
+	endpoints []Endpoint
+	logger    *slog.Logger
+
+	mu   sync.Mutex
+	down map[int]time.Time
+	last int
+}
+
+// NewFailoverRoundTripper returns a FailoverRoundTripper for the given endpoints,
+// which are tried in the given order. Failovers are logged with the logger.
+func NewFailoverRoundTripper(endpoints []Endpoint, logger *slog.Logger) *FailoverRoundTripper {
+	return &FailoverRoundTripper{
+		endpoints: endpoints,
+		logger:    logger,
+		down:      make(map[int]time.Time),
+	}
+}
+
+// Endpoint returns the URL of the endpoint that answered the last request
+func (f *FailoverRoundTripper) Endpoint() url.URL {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.endpoints[f.last].URL
+}
+
+func (f *FailoverRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	order := f.order()
+	errs := make([]error, 0, len(order))
+
+	for n, i := range order {
+		ep := f.endpoints[i]
+
+		r, err := f.rewrite(req, ep, n > 0)
+
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := ep.Transport.RoundTrip(r)
+
+		if err == nil && resp.StatusCode < http.StatusInternalServerError {
+			f.markUp(i)
+			return resp, nil
+		}
+
+		f.markDown(i)
+
+		// The last response is returned as is, so the caller sees the error of the API
+		retry := n < len(order)-1 && (req.Body == nil || req.GetBody != nil) && retryable(req, err)
+
+		if err != nil {
+			if !retry && !retryable(req, err) {
+				return nil, err
+			}
+
+			errs = append(errs, fmt.Errorf("%s: %w", ep.URL.Redacted(), err))
+		} else {
+			if !retry {
+				return resp, nil
+			}
+
+			errs = append(errs, fmt.Errorf("%s: %s", ep.URL.Redacted(), resp.Status))
+			resp.Body.Close()
+		}
+
+		if !retry {
+			break
+		}
+
+		f.logger.Warn("Zammad endpoint failed, trying next endpoint", "endpoint", ep.URL.Redacted(), "error", errs[len(errs)-1])
+	}
+
+	return nil, fmt.Errorf("all Zammad endpoints failed: %w", errors.Join(errs...))
+}
+
+// retryable reports if the failed request can be sent to another endpoint.
+// Idempotent requests are always retried, others only if the endpoint could not be connected,
+// since they might have been processed already.
+func retryable(req *http.Request, err error) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return true
+	}
+
+	var opErr *net.OpError
+
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// CloseIdleConnections closes the idle connections of all endpoints
+func (f *FailoverRoundTripper) CloseIdleConnections() {
+	for _, ep := range f.endpoints {
+		if ci, ok := ep.Transport.(interface{ CloseIdleConnections() }); ok {
+			ci.CloseIdleConnections()
+		}
+	}
+}
+
+// order returns the indices of the endpoints to try,
+// the endpoints that failed recently are tried last
+func (f *FailoverRoundTripper) order() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	healthy := make([]int, 0, len(f.endpoints))
+	failed := make([]int, 0)
+
+	now := time.Now()
+
+	for i := range f.endpoints {
+		if until, ok := f.down[i]; ok && now.Before(until) {
+			failed = append(failed, i)
+			continue
+		}
+
+		healthy = append(healthy, i)
+	}
+
+	return append(healthy, failed...)
+}
+
+func (f *FailoverRoundTripper) markUp(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.down, i)
+	f.last = i
+}
+
+func (f *FailoverRoundTripper) markDown(i int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.down[i] = time.Now().Add(failoverCooldown)
+}
+
+// rewrite returns a copy of the request for the given endpoint.
+// The body is requested again for retries, since the first attempt consumed it.
+func (f *FailoverRoundTripper) rewrite(req *http.Request, ep Endpoint, retry bool) (*http.Request, error) {
+	base := strings.TrimSuffix(f.endpoints[0].URL.Path, "/")
+
+	u := *req.URL
+	u.Scheme = ep.URL.Scheme
+	u.Host = ep.URL.Host
+	u.Path = strings.TrimSuffix(ep.URL.Path, "/") + strings.TrimPrefix(req.URL.Path, base)
+	u.RawPath = ""
+
+	r := req.Clone(req.Context())
+	r.URL = &u
+	r.Host = ""
+
+	if retry && req.Body != nil {
+		body, err := req.GetBody()
+
+		if err != nil {
+			return nil, err
+		}
+
+		r.Body = body
+	}
+
+	return r, nil
+}
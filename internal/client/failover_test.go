@@ -0,0 +1,201 @@
+package client
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+)
+
+func newFailoverClient(t *testing.T, urls ...string) (*Client, *FailoverRoundTripper) {
+	t.Helper()
+
+	endpoints := make([]Endpoint, 0, len(urls))
+
+	for _, raw := range urls {
+		u, _ := url.Parse(raw)
+		endpoints = append(endpoints, Endpoint{URL: *u, Transport: http.DefaultTransport})
+	}
+
+	f := NewFailoverRoundTripper(endpoints, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	return NewClient(endpoints[0].URL, f), f
+}
+
+func TestFailoverRoundTripper_ConnectionError(t *testing.T) {
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/tickets/search" {
+			t.Errorf("Expected search path got: %s", r.URL.Path)
+		}
+
+		w.Write([]byte(`[]`))
+	}))
+
+	defer standby.Close()
+
+	c, f := newFailoverClient(t, primary.URL, standby.URL)
+
+	_, err := c.SearchTickets(context.Background(), "MyHost", "")
+
+	if err != nil {
+		t.Fatalf("Did not except error: %v", err)
+	}
+
+	if u := f.Endpoint(); u.String() != standby.URL {
+		t.Errorf("Expected standby endpoint got: %s", u.String())
+	}
+}
+
+func TestFailoverRoundTripper_ServerError(t *testing.T) {
+	var requests int
+
+	// The proxy might have passed the request to Zammad before it failed
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	defer primary.Close()
+
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`[]`))
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	defer standby.Close()
+
+	c, _ := newFailoverClient(t, primary.URL, standby.URL)
+
+	// Creating an article is not sent again, to prevent duplicates
+	err := c.AddArticleToTicket(context.Background(), zammad.Article{TicketID: 1337})
+
+	if err == nil || !strings.Contains(err.Error(), "could not add article") || requests != 0 {
+		t.Fatalf("Expected error of the primary without failover got: %v (%d requests to standby)", err, requests)
+	}
+
+	// Searches are sent to the standby
+	c, f := newFailoverClient(t, primary.URL, standby.URL)
+
+	_, err = c.SearchTickets(context.Background(), "MyHost", "")
+
+	if err != nil || requests != 1 {
+		t.Fatalf("Expected search on the standby got: %v (%d requests to standby)", err, requests)
+	}
+
+	if u := f.Endpoint(); u.String() != standby.URL {
+		t.Errorf("Expected standby endpoint got: %s", u.String())
+	}
+}
+
+func TestFailoverRoundTripper_ConnectionErrorPost(t *testing.T) {
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	// The standby is reachable below a path prefix and receives the body again
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+
+		if r.URL.Path != "/zammad/api/v1/ticket_articles" || !strings.Contains(string(b), "1337") {
+			t.Errorf("Expected article request got: %s %s", r.URL.Path, string(b))
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	defer standby.Close()
+
+	c, f := newFailoverClient(t, primary.URL, standby.URL+"/zammad/")
+
+	err := c.AddArticleToTicket(context.Background(), zammad.Article{TicketID: 1337})
+
+	if err != nil {
+		t.Fatalf("Did not except error: %v", err)
+	}
+
+	if u := f.Endpoint(); u.String() != standby.URL+"/zammad/" {
+		t.Errorf("Expected standby endpoint got: %s", u.String())
+	}
+}
+
+func TestFailoverRoundTripper_Cooldown(t *testing.T) {
+	var requests int
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	defer primary.Close()
+
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+
+	defer standby.Close()
+
+	c, _ := newFailoverClient(t, primary.URL, standby.URL)
+
+	for range 3 {
+		_, _ = c.SearchTickets(context.Background(), "MyHost", "")
+	}
+
+	// The failed primary is skipped after the first failure
+	if requests != 1 {
+		t.Errorf("Expected a single request to the primary got: %d", requests)
+	}
+}
+
+func TestFailoverRoundTripper_AllFailed(t *testing.T) {
+	primary := httptest.NewServer(http.NotFoundHandler())
+	primary.Close()
+
+	standby := httptest.NewServer(http.NotFoundHandler())
+	standby.Close()
+
+	c, _ := newFailoverClient(t, primary.URL, standby.URL)
+
+	_, err := c.SearchTickets(context.Background(), "MyHost", "")
+
+	if err == nil || !strings.Contains(err.Error(), "all Zammad endpoints failed") {
+		t.Errorf("Expected failover error got: %v", err)
+	}
+}
+
+func TestFailoverRoundTripper_ClientError(t *testing.T) {
+	var standbyRequests int
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	defer primary.Close()
+
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		standbyRequests++
+	}))
+
+	defer standby.Close()
+
+	c, _ := newFailoverClient(t, primary.URL, standby.URL)
+
+	_, err := c.SearchTickets(context.Background(), "MyHost", "")
+
+	// Client errors are returned by the API and not a reason to fail over
+	if err == nil || standbyRequests != 0 {
+		t.Errorf("Expected error from primary got: %v, %d standby requests", err, standbyRequests)
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxLoggedBody is the maximum number of bytes logged of a request or response body
+const maxLoggedBody = 4096
+
+const redacted = "REDACTED"
+
+// sensitiveHeaders are never logged in plain text
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// sensitiveField matches JSON string fields that contain credentials, e.g. "password": "foo"
+var sensitiveField = regexp.MustCompile(`(?i)("[^"]*(?:token|password|secret)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// sensitiveParam matches the names of URL parameters that contain credentials
+var sensitiveParam = regexp.MustCompile(`(?i)token|password|secret`)
+
+// loggingRoundTripper logs each request and its response
+type loggingRoundTripper struct {
+	logger *slog.Logger
+	rt     http.RoundTripper
+}
+
+// NewLoggingRoundTripper returns a RoundTripper that logs method, URL, status,
+// latency, headers and bodies of each request at debug level.
+// Credentials in headers, URL parameters and JSON bodies are redacted.
+func NewLoggingRoundTripper(logger *slog.Logger, rt http.RoundTripper) http.RoundTripper {
+	return &loggingRoundTripper{
+		logger: logger,
+		rt:     rt,
+	}
+}
+
+func (l *loggingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	if !l.logger.Enabled(ctx, slog.LevelDebug) {
+		return l.rt.RoundTrip(req)
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", redactURL(req.URL)),
+		slog.Any("request_headers", redactHeaders(req.Header)),
+	}
+
+	// The body is read from a copy to leave the request untouched
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(body)
+			body.Close()
+
+			attrs = append(attrs, slog.String("request_body", redactBody(b)))
+		}
+	}
+
+	start := time.Now()
+
+	resp, err := l.rt.RoundTrip(req)
+
+	attrs = append(attrs, slog.Duration("latency", time.Since(start)))
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+		l.log(ctx, "http request failed", attrs)
+
+		return resp, err
+	}
+
+	attrs = append(attrs,
+		slog.Int("status", resp.StatusCode),
+		slog.Any("response_headers", redactHeaders(resp.Header)))
+
+	// Buffer the response body, so it can still be read by the caller
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(b))
+
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	attrs = append(attrs, slog.String("response_body", redactBody(b)))
+
+	l.log(ctx, "http request", attrs)
+
+	return resp, nil
+}
+
+func (l *loggingRoundTripper) log(ctx context.Context, msg string, attrs []slog.Attr) {
+	l.logger.LogAttrs(ctx, slog.LevelDebug, msg, attrs...)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped RoundTripper
+func (l *loggingRoundTripper) CloseIdleConnections() {
+	if ci, ok := l.rt.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// redactHeaders returns the headers with the credentials replaced
+func redactHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+
+	for k := range h {
+		headers[k] = h.Get(k)
+	}
+
+	for _, k := range sensitiveHeaders {
+		if _, ok := headers[k]; ok {
+			headers[k] = redacted
+		}
+	}
+
+	for k := range headers {
+		if strings.Contains(strings.ToLower(k), "token") {
+			headers[k] = redacted
+		}
+	}
+
+	return headers
+}
+
+// redactURL returns the URL with user info and credential parameters replaced
+func redactURL(u *url.URL) string {
+	r := *u
+
+	if r.User != nil {
+		r.User = url.User(redacted)
+	}
+
+	query := r.Query()
+
+	for k := range query {
+		if sensitiveParam.MatchString(k) {
+			query.Set(k, redacted)
+		}
+	}
+
+	if len(query) > 0 {
+		r.RawQuery = query.Encode()
+	}
+
+	return r.String()
+}
+
+// redactBody returns the body with credentials in JSON fields replaced,
+// the body is truncated to maxLoggedBody bytes
+func redactBody(b []byte) string {
+	s := sensitiveField.ReplaceAllString(string(b), `$1"`+redacted+`"`)
+
+	if len(s) > maxLoggedBody {
+		s = s[:maxLoggedBody] + "...(truncated)"
+	}
+
+	return s
+}
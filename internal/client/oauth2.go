@@ -0,0 +1,194 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenExpiryDelta is subtracted from the token lifetime,
+// so tokens are refreshed before they expire during a request
+const tokenExpiryDelta = 30 * time.Second
+
+// ClientCredentials holds the configuration of the OAuth2 client credentials flow
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+// tokenResponse represents the response of the token endpoint
+// https://datatracker.ietf.org/doc/html/rfc6749#section-5.1
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// oauth2RoundTripper adds an access token obtained via the client credentials flow
+type oauth2RoundTripper struct {
+	cfg ClientCredentials
+	// tokenClient is used for the requests to the token endpoint
+	tokenClient *http.Client
+	rt          http.RoundTripper
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewOAuth2RoundTripper returns a RoundTripper that sends an Authorization: Bearer header
+// with an access token obtained via the OAuth2 client credentials flow.
+// The token is cached until it expires and fetched again if the server rejects it.
+// The token endpoint is requested via tokenRT.
+func NewOAuth2RoundTripper(cfg ClientCredentials, tokenRT, rt http.RoundTripper) http.RoundTripper {
+	return &oauth2RoundTripper{
+		cfg:         cfg,
+		tokenClient: &http.Client{Transport: tokenRT},
+		rt:          rt,
+	}
+}
+
+func (o *oauth2RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, fetched, err := o.getToken(req.Context(), false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := o.rt.RoundTrip(withBearer(req, token))
+
+	// A cached token might have been revoked, retry once with a new token
+	// if the request can be sent again
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || fetched {
+		return resp, err
+	}
+
+	if req.Body != nil && req.GetBody == nil {
+		return resp, err
+	}
+
+	resp.Body.Close()
+
+	token, _, err = o.getToken(req.Context(), true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	retry := withBearer(req, token)
+
+	if req.Body != nil {
+		retry.Body, err = req.GetBody()
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return o.rt.RoundTrip(retry)
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped RoundTripper
+func (o *oauth2RoundTripper) CloseIdleConnections() {
+	if ci, ok := o.rt.(interface{ CloseIdleConnections() }); ok {
+		ci.CloseIdleConnections()
+	}
+}
+
+// withBearer returns a copy of the request with the token set
+func withBearer(req *http.Request, token string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+
+	return r
+}
+
+// getToken returns the cached token or fetches a new one if it expired or refresh is set.
+// It reports if the token was fetched.
+func (o *oauth2RoundTripper) getToken(ctx context.Context, refresh bool) (string, bool, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !refresh && o.token != "" && time.Now().Before(o.expiry) {
+		return o.token, false, nil
+	}
+
+	t, err := o.fetchToken(ctx)
+
+	if err != nil {
+		return "", false, err
+	}
+
+	o.token = t.AccessToken
+	// Tokens without expiry are used until the server rejects them
+	o.expiry = time.Now().Add(100 * 365 * 24 * time.Hour)
+
+	if t.ExpiresIn > 0 {
+		o.expiry = time.Now().Add(time.Duration(t.ExpiresIn)*time.Second - tokenExpiryDelta)
+	}
+
+	return o.token, true, nil
+}
+
+// fetchToken requests a new access token from the token endpoint
+func (o *oauth2RoundTripper) fetchToken(ctx context.Context) (tokenResponse, error) {
+	var t tokenResponse
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+
+	if len(o.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(o.cfg.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.cfg.TokenURL, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return t, fmt.Errorf("could not create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	// The client credentials are sent form encoded via basic auth
+	// https://datatracker.ietf.org/doc/html/rfc6749#section-2.3.1
+	req.SetBasicAuth(url.QueryEscape(o.cfg.ClientID), url.QueryEscape(o.cfg.ClientSecret))
+
+	resp, err := o.tokenClient.Do(req)
+
+	if err != nil {
+		return t, fmt.Errorf("could not fetch OAuth2 token: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	b, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return t, fmt.Errorf("could not fetch OAuth2 token: %s - Error: %s", o.cfg.TokenURL, string(b))
+	}
+
+	err = json.Unmarshal(b, &t)
+
+	if err != nil {
+		return t, fmt.Errorf("unable to parse OAuth2 token: %w", err)
+	}
+
+	if t.AccessToken == "" {
+		return t, errors.New("token endpoint returned no access_token")
+	}
+
+	if t.TokenType != "" && !strings.EqualFold(t.TokenType, "bearer") {
+		return t, fmt.Errorf("unsupported OAuth2 token type '%s'", t.TokenType)
+	}
+
+	return t, nil
+}
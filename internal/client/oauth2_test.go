@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+)
+
+func newTokenServer(t *testing.T, expiresIn int, fetched *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The credentials are form encoded
+		id, secret, _ := r.BasicAuth()
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+		_ = r.ParseForm()
+
+		if id != "notify_zammad" || secret != "s3cr%t" || r.Form.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": "invalid_client"}`))
+
+			return
+		}
+
+		if r.Form.Get("scope") != "zammad.read zammad.write" {
+			t.Errorf("Expected scopes got: %s", r.Form.Get("scope"))
+		}
+
+		n := fetched.Add(1)
+
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": %d}`, n, expiresIn)
+	}))
+
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func TestOAuth2RoundTripper(t *testing.T) {
+	var fetched atomic.Int32
+
+	tokenServer := newTokenServer(t, 3600, &fetched)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.Write([]byte(`[]`))
+	}))
+
+	defer ts.Close()
+
+	cfg := ClientCredentials{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "notify_zammad",
+		ClientSecret: "s3cr%t",
+		Scopes:       []string{"zammad.read", "zammad.write"},
+	}
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, NewOAuth2RoundTripper(cfg, http.DefaultTransport, http.DefaultTransport))
+
+	for range 3 {
+		_, err := c.SearchTickets(context.Background(), "MyHost", "")
+
+		if err != nil {
+			t.Fatalf("Did not except error: %v", err)
+		}
+	}
+
+	// The token is cached
+	if fetched.Load() != 1 {
+		t.Errorf("Expected a single token request got: %d", fetched.Load())
+	}
+}
+
+func TestOAuth2RoundTripper_Refresh(t *testing.T) {
+	var fetched atomic.Int32
+
+	// Tokens expiring within the expiry delta are fetched again for each request
+	tokenServer := newTokenServer(t, 10, &fetched)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+
+	defer ts.Close()
+
+	cfg := ClientCredentials{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "notify_zammad",
+		ClientSecret: "s3cr%t",
+		Scopes:       []string{"zammad.read", "zammad.write"},
+	}
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, NewOAuth2RoundTripper(cfg, http.DefaultTransport, http.DefaultTransport))
+
+	for range 2 {
+		_, _ = c.SearchTickets(context.Background(), "MyHost", "")
+	}
+
+	if fetched.Load() != 2 {
+		t.Errorf("Expected two token requests got: %d", fetched.Load())
+	}
+}
+
+func TestOAuth2RoundTripper_Revoked(t *testing.T) {
+	var fetched atomic.Int32
+
+	tokenServer := newTokenServer(t, 3600, &fetched)
+
+	var requests atomic.Int32
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		// The first token is revoked after the first request
+		if r.Header.Get("Authorization") == "Bearer token-1" && requests.Load() > 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		b, _ := io.ReadAll(r.Body)
+
+		if !strings.Contains(string(b), "1337") {
+			t.Errorf("Expected request body got: %s", string(b))
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	defer ts.Close()
+
+	cfg := ClientCredentials{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "notify_zammad",
+		ClientSecret: "s3cr%t",
+		Scopes:       []string{"zammad.read", "zammad.write"},
+	}
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, NewOAuth2RoundTripper(cfg, http.DefaultTransport, http.DefaultTransport))
+
+	for range 2 {
+		err := c.AddArticleToTicket(context.Background(), zammad.Article{TicketID: 1337})
+
+		if err != nil {
+			t.Fatalf("Did not except error: %v", err)
+		}
+	}
+
+	if fetched.Load() != 2 || requests.Load() != 3 {
+		t.Errorf("Expected retry with new token got: %d tokens %d requests", fetched.Load(), requests.Load())
+	}
+}
+
+func TestOAuth2RoundTripper_Error(t *testing.T) {
+	var fetched atomic.Int32
+
+	tokenServer := newTokenServer(t, 3600, &fetched)
+
+	cfg := ClientCredentials{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "notify_zammad",
+		ClientSecret: "wrong",
+	}
+
+	u, _ := url.Parse("http://localhost:9999")
+
+	c := NewClient(*u, NewOAuth2RoundTripper(cfg, http.DefaultTransport, http.DefaultTransport))
+
+	_, err := c.SearchTickets(context.Background(), "MyHost", "")
+
+	if err == nil || !strings.Contains(err.Error(), "could not fetch OAuth2 token") || !strings.Contains(err.Error(), "invalid_client") {
+		t.Errorf("Expected token error got: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"context"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+)
+
+// TicketService is the part of the Zammad API used to manage the tickets.
+// It is implemented by the Client, other implementations can wrap a
+// TicketService (e.g. for logging or caching) or replace it in tests.
+type TicketService interface {
+	SearchTickets(ctx context.Context, hostname, service string) ([]zammad.Ticket, error)
+	SearchOpenTickets(ctx context.Context) ([]zammad.Ticket, error)
+	CreateTicket(ctx context.Context, ticket zammad.NewTicket) (zammad.Ticket, error)
+	AddArticleToTicket(ctx context.Context, article zammad.Article) error
+	UpdateTicket(ctx context.Context, ticketID int, fields map[string]any) (zammad.Ticket, error)
+	UpdateTicketState(ctx context.Context, ticket zammad.Ticket, state zammad.TicketState) error
+	AddTag(ctx context.Context, ticketID int, tag string) error
+	LinkTickets(ctx context.Context, source, target zammad.Ticket, linkType zammad.LinkType) error
+	TicketURL(ticket zammad.Ticket) string
+}
+
+var _ TicketService = (*Client)(nil)
+
+// Directory is the part of the Zammad API used to look up users, groups and organizations
+type Directory interface {
+	SearchUsers(ctx context.Context, query string) ([]zammad.User, error)
+	GetUser(ctx context.Context, userID int) (zammad.User, error)
+	CreateUser(ctx context.Context, user zammad.User) (zammad.User, error)
+	Groups(ctx context.Context) ([]zammad.Group, error)
+	GetGroup(ctx context.Context, groupID int) (zammad.Group, error)
+	SearchOrganizations(ctx context.Context, query string) ([]zammad.Organization, error)
+	GetOrganization(ctx context.Context, organizationID int) (zammad.Organization, error)
+}
+
+var _ Directory = (*Client)(nil)
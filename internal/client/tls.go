@@ -0,0 +1,94 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TLSOptions are additional TLS settings for the connection to the API
+type TLSOptions struct {
+	// MinVersion is the minimum TLS version, 0 uses the Go default
+	MinVersion uint16
+	// Pins are SHA-256 fingerprints of the leaf certificate or its public key (SPKI).
+	// If set, the server certificate is verified against the pins instead of the CAs.
+	Pins [][]byte
+}
+
+// Apply applies the options to the given TLS configuration
+func (o TLSOptions) Apply(cfg *tls.Config) {
+	if o.MinVersion != 0 {
+		cfg.MinVersion = o.MinVersion
+	}
+
+	if len(o.Pins) > 0 {
+		pins := o.Pins
+		// The chain is not verified against the CAs,
+		// the pinned fingerprint is verified instead
+		cfg.InsecureSkipVerify = true // nolint: gosec
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyPins(rawCerts, pins)
+		}
+	}
+}
+
+// verifyPins checks if the leaf certificate or its public key matches one of the pins
+func verifyPins(rawCerts [][]byte, pins [][]byte) error {
+	if len(rawCerts) == 0 {
+		return errors.New("server presented no certificate")
+	}
+
+	leaf, err := x509.ParseCertificate(rawCerts[0])
+
+	if err != nil {
+		return fmt.Errorf("could not parse server certificate: %w", err)
+	}
+
+	certSum := sha256.Sum256(leaf.Raw)
+	spkiSum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+
+	for _, pin := range pins {
+		if bytes.Equal(pin, certSum[:]) || bytes.Equal(pin, spkiSum[:]) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("server certificate does not match pinned fingerprint, certificate sha256: %s", hex.EncodeToString(certSum[:]))
+}
+
+// ParseTLSVersion parses a TLS version like 1.2
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version '%s'. Currently supported: 1.0/1.1/1.2/1.3", version)
+	}
+}
+
+// ParsePin parses a SHA-256 fingerprint, either hex encoded with optional colons
+// as printed by 'openssl x509 -fingerprint -sha256' or base64 encoded
+// with optional sha256// prefix as used by curl's --pinnedpubkey.
+func ParsePin(pin string) ([]byte, error) {
+	if b, err := hex.DecodeString(strings.ReplaceAll(pin, ":", "")); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+
+	if b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256//")); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("invalid SHA-256 fingerprint '%s'", pin)
+}
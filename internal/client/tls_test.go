@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestTLSOptions_Pins(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+
+	defer ts.Close()
+
+	certSum := sha256.Sum256(ts.Certificate().Raw)
+	spkiSum := sha256.Sum256(ts.Certificate().RawSubjectPublicKeyInfo)
+
+	tests := map[string]struct {
+		pins     [][]byte
+		expected string
+	}{
+		"no-pin":      {nil, "certificate signed by unknown authority"},
+		"certificate": {[][]byte{certSum[:]}, ""},
+		"spki":        {[][]byte{make([]byte, 32), spkiSum[:]}, ""},
+		"wrong-pin":   {[][]byte{make([]byte, 32)}, "does not match pinned fingerprint"},
+	}
+
+	u, _ := url.Parse(ts.URL)
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := &tls.Config{}
+			TLSOptions{Pins: test.pins}.Apply(cfg)
+
+			c := NewClient(*u, &http.Transport{TLSClientConfig: cfg})
+
+			_, err := c.SearchTickets(context.Background(), "MyHost", "")
+
+			if test.expected == "" && err != nil {
+				t.Errorf("Did not except error: %v", err)
+			}
+
+			if test.expected != "" && (err == nil || !strings.Contains(err.Error(), test.expected)) {
+				t.Errorf("Expected error %s got: %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestTLSOptions_MinVersion(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[]`))
+	}))
+
+	ts.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	ts.StartTLS()
+
+	defer ts.Close()
+
+	v, _ := ParseTLSVersion("1.3")
+
+	cfg := &tls.Config{InsecureSkipVerify: true} // nolint: gosec
+	TLSOptions{MinVersion: v}.Apply(cfg)
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, &http.Transport{TLSClientConfig: cfg})
+
+	_, err := c.SearchTickets(context.Background(), "MyHost", "")
+
+	if err == nil || !strings.Contains(err.Error(), "protocol version") {
+		t.Errorf("Expected protocol version error got: %v", err)
+	}
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	v, err := ParseTLSVersion("1.2")
+
+	if err != nil || v != tls.VersionTLS12 {
+		t.Errorf("Expected TLS 1.2 got: %d %v", v, err)
+	}
+
+	_, err = ParseTLSVersion("1.4")
+
+	if err == nil {
+		t.Error("Expected error for unsupported version")
+	}
+}
+
+func TestParsePin(t *testing.T) {
+	sum := sha256.Sum256([]byte("foo"))
+
+	colons := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	for i := len(colons) - 2; i > 0; i -= 2 {
+		colons = colons[:i] + ":" + colons[i:]
+	}
+
+	for _, pin := range []string{
+		hex.EncodeToString(sum[:]),
+		colons,
+		base64.StdEncoding.EncodeToString(sum[:]),
+		"sha256//" + base64.StdEncoding.EncodeToString(sum[:]),
+	} {
+		actual, err := ParsePin(pin)
+
+		if err != nil || string(actual) != string(sum[:]) {
+			t.Errorf("Could not parse pin %s: %v", pin, err)
+		}
+	}
+
+	_, err := ParsePin("abcd")
+
+	if err == nil {
+		t.Error("Expected error for invalid pin")
+	}
+}
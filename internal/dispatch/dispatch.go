@@ -0,0 +1,116 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sync"
+)
+
+var (
+	// ErrQueueFull is returned if the queue of the responsible worker is full
+	ErrQueueFull = errors.New("queue is full")
+	// ErrClosed is returned if the Dispatcher no longer accepts jobs
+	ErrClosed = errors.New("dispatcher is closed")
+)
+
+// Dispatcher runs jobs in a pool of workers.
+// Jobs with the same key are always handled by the same worker,
+// thus they are processed one after another in the order they were submitted.
+type Dispatcher struct {
+	queues []chan job
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	closed bool
+}
+
+type job struct {
+	fn   func() error
+	done chan error
+}
+
+// New creates a Dispatcher with the given number of workers,
+// each with a queue of the given size
+func New(workers, queueSize int) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	d := &Dispatcher{
+		queues: make([]chan job, workers),
+	}
+
+	for i := range d.queues {
+		d.queues[i] = make(chan job, queueSize)
+
+		d.wg.Add(1)
+
+		go d.work(d.queues[i])
+	}
+
+	return d
+}
+
+func (d *Dispatcher) work(queue chan job) {
+	defer d.wg.Done()
+
+	for j := range queue {
+		j.done <- j.fn()
+	}
+}
+
+// Submit queues the given function for the worker responsible for the key
+// and waits until it is done. If the context is done before the function
+// returns, the context's error is returned, the function is still executed.
+func (d *Dispatcher) Submit(ctx context.Context, key string, fn func() error) error {
+	j := job{
+		fn:   fn,
+		done: make(chan error, 1),
+	}
+
+	d.mu.RLock()
+
+	if d.closed {
+		d.mu.RUnlock()
+		return ErrClosed
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	// nolint: gosec
+	queue := d.queues[h.Sum32()%uint32(len(d.queues))]
+
+	select {
+	case queue <- j:
+	default:
+		d.mu.RUnlock()
+		return ErrQueueFull
+	}
+
+	d.mu.RUnlock()
+
+	select {
+	case err := <-j.done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting new jobs and waits until all queued jobs are done
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+
+	if !d.closed {
+		d.closed = true
+
+		for _, q := range d.queues {
+			close(q)
+		}
+	}
+
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
@@ -0,0 +1,156 @@
+package dispatch
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDispatcherSerializesKeys(t *testing.T) {
+	d := New(4, 10)
+
+	var mu sync.Mutex
+
+	running := map[string]bool{}
+	order := []int{}
+
+	// The first job blocks until all jobs are queued
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	queued := func() int {
+		n := 0
+
+		for _, q := range d.queues {
+			n += len(q)
+		}
+
+		return n
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range 5 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := d.Submit(context.Background(), "MyHost!MyService", func() error {
+				if i == 0 {
+					close(started)
+					<-release
+				}
+
+				mu.Lock()
+				if running["MyHost!MyService"] {
+					t.Error("Expected jobs with the same key to run one after another")
+				}
+				running["MyHost!MyService"] = true
+				mu.Unlock()
+
+				time.Sleep(time.Millisecond)
+
+				mu.Lock()
+				running["MyHost!MyService"] = false
+				order = append(order, i)
+				mu.Unlock()
+
+				return nil
+			})
+
+			if err != nil {
+				t.Errorf("Did not expect error: %v", err)
+			}
+		}()
+
+		// Submit in order to ensure the queue order
+		if i == 0 {
+			<-started
+			continue
+		}
+
+		for queued() < i {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	close(release)
+
+	wg.Wait()
+	d.Close()
+
+	for i := range order {
+		if order[i] != i {
+			t.Errorf("Expected jobs to run in submitted order got: %v", order)
+			break
+		}
+	}
+}
+
+func TestDispatcherReturnsError(t *testing.T) {
+	d := New(1, 1)
+
+	expected := errors.New("could not create ticket")
+
+	err := d.Submit(context.Background(), "MyHost!", func() error {
+		return expected
+	})
+
+	if !errors.Is(err, expected) {
+		t.Errorf("Expected job error got: %v", err)
+	}
+
+	d.Close()
+
+	err = d.Submit(context.Background(), "MyHost!", func() error {
+		return nil
+	})
+
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected closed error got: %v", err)
+	}
+}
+
+func TestDispatcherQueueFull(t *testing.T) {
+	d := New(1, 1)
+	defer d.Close()
+
+	block := make(chan struct{})
+	started := make(chan struct{})
+
+	go func() {
+		_ = d.Submit(context.Background(), "a", func() error {
+			close(started)
+			<-block
+			return nil
+		})
+	}()
+
+	<-started
+
+	// A cancelled context lets Submit return as soon as the job is queued
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The second job fills the queue while the worker is blocked
+	err := d.Submit(ctx, "a", func() error {
+		return nil
+	})
+
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected job to be queued got: %v", err)
+	}
+
+	err = d.Submit(ctx, "a", func() error {
+		return nil
+	})
+
+	close(block)
+
+	if !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Expected queue full error got: %v", err)
+	}
+}
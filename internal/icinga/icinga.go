@@ -0,0 +1,229 @@
+package icinga
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Client is a small client for the Icinga 2 REST API
+type Client struct {
+	Client http.Client
+	URL    url.URL
+}
+
+// AcknowledgeRequest represents the request body for the
+// /v1/actions/acknowledge-problem endpoint
+type AcknowledgeRequest struct {
+	Type       string            `json:"type"`
+	Filter     string            `json:"filter"`
+	FilterVars map[string]string `json:"filter_vars"`
+	Author     string            `json:"author"`
+	Comment    string            `json:"comment"`
+	Notify     bool              `json:"notify"`
+	Sticky     bool              `json:"sticky"`
+}
+
+// ActionResult represents a single result of an Icinga 2 API action
+type ActionResult struct {
+	Code   float64 `json:"code"`
+	Status string  `json:"status"`
+}
+
+// ActionResponse represents the response of an Icinga 2 API action
+type ActionResponse struct {
+	Results []ActionResult `json:"results"`
+	Error   float64        `json:"error,omitempty"`
+	Status  string         `json:"status,omitempty"`
+}
+
+// ObjectState represents the current state of an Icinga 2 Host or Service
+type ObjectState struct {
+	State        float64 `json:"state"`
+	Acknowledged float64 `json:"acknowledgement"`
+	LastCheck    struct {
+		Output string `json:"output"`
+	} `json:"last_check_result"`
+}
+
+// OK reports if the object is in the state UP (hosts) or OK (services),
+// which both are represented by 0
+func (s ObjectState) OK() bool {
+	return s.State == 0
+}
+
+// objectsResponse represents the response of the /v1/objects endpoints
+type objectsResponse struct {
+	Results []struct {
+		Name  string      `json:"name"`
+		Type  string      `json:"type"`
+		Attrs ObjectState `json:"attrs"`
+	} `json:"results"`
+}
+
+// ErrObjectNotFound is returned if the requested object does not exist in Icinga
+var ErrObjectNotFound = errors.New("object not found")
+
+func NewClient(url url.URL, rt http.RoundTripper) *Client {
+	// Small wrapper for the http.Client that we feed with a custom RoundTripper
+	c := &http.Client{
+		Transport: rt,
+	}
+
+	return &Client{
+		URL:    url,
+		Client: *c,
+	}
+}
+
+// AcknowledgeProblem acknowledges the problem of the given host or service.
+// If no service is provided the host problem is acknowledged.
+func (c *Client) AcknowledgeProblem(ctx context.Context, hostname, service, author, comment string) error {
+	ack := AcknowledgeRequest{
+		Type:   "Host",
+		Filter: "host.name == host_name",
+		FilterVars: map[string]string{
+			"host_name": hostname,
+		},
+		Author:  author,
+		Comment: comment,
+	}
+
+	if service != "" {
+		ack.Type = "Service"
+		ack.Filter = "host.name == host_name && service.name == service_name"
+		ack.FilterVars["service_name"] = service
+	}
+
+	u := c.URL.JoinPath("/v1/actions/acknowledge-problem")
+
+	data, err := json.Marshal(ack)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewBuffer(data))
+
+	if err != nil {
+		return fmt.Errorf("could not create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+
+	if err != nil {
+		return fmt.Errorf("could not acknowledge problem: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("authentication failed for %s", c.URL.String())
+	}
+
+	// Retrieve response body since to have details on potential errors
+	b, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return fmt.Errorf("could not read acknowledge response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not acknowledge problem: %s - Error: %s", u.String(), string(b))
+	}
+
+	var result ActionResponse
+
+	err = json.Unmarshal(b, &result)
+
+	if err != nil {
+		return fmt.Errorf("unable to parse acknowledge response: %w", err)
+	}
+
+	// The API returns one result per matching object,
+	// each object might fail on its own.
+	for _, r := range result.Results {
+		if r.Code != http.StatusOK {
+			return fmt.Errorf("could not acknowledge problem: %s", r.Status)
+		}
+	}
+
+	return nil
+}
+
+// GetObjectState returns the current state of the given host or service.
+// If no service is provided the state of the host is returned.
+// If the object does not exist ErrObjectNotFound is returned.
+func (c *Client) GetObjectState(ctx context.Context, hostname, service string) (ObjectState, error) {
+	var state ObjectState
+
+	u := c.URL.JoinPath("/v1/objects/hosts", url.PathEscape(hostname))
+
+	if service != "" {
+		u = c.URL.JoinPath("/v1/objects/services", url.PathEscape(hostname+"!"+service))
+	}
+
+	// Only request the attributes we care about
+	attrs := u.Query()
+	attrs.Add("attrs", "state")
+	attrs.Add("attrs", "acknowledgement")
+	attrs.Add("attrs", "last_check_result")
+	u.RawQuery = attrs.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+
+	if err != nil {
+		return state, fmt.Errorf("could not create request: %w", err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.Client.Do(req)
+
+	if err != nil {
+		return state, fmt.Errorf("could not query object state: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return state, fmt.Errorf("authentication failed for %s", c.URL.String())
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return state, ErrObjectNotFound
+	}
+
+	// Retrieve response body since to have details on potential errors
+	b, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return state, fmt.Errorf("could not read object state: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return state, fmt.Errorf("could not query object state: %s - Error: %s", u.String(), string(b))
+	}
+
+	var result objectsResponse
+
+	err = json.Unmarshal(b, &result)
+
+	if err != nil {
+		return state, fmt.Errorf("unable to parse object state: %w", err)
+	}
+
+	if len(result.Results) == 0 {
+		return state, ErrObjectNotFound
+	}
+
+	return result.Results[0].Attrs, nil
+}
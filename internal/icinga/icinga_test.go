@@ -0,0 +1,207 @@
+package icinga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	checkhttpconfig "github.com/NETWAYS/go-check-network/http/config"
+)
+
+func TestAcknowledgeProblemService(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			t.Errorf("Expected POST request, got %s", r.Method)
+		}
+
+		if r.URL.Path != "/v1/actions/acknowledge-problem" {
+			t.Errorf("Expected acknowledge-problem action, got %s", r.URL.Path)
+		}
+
+		var ack AcknowledgeRequest
+
+		err := json.NewDecoder(r.Body).Decode(&ack)
+		if err != nil {
+			t.Errorf("Could not decode request: %v", err)
+		}
+
+		if ack.Type != "Service" {
+			t.Errorf("Expected type Service got: %s", ack.Type)
+		}
+
+		if ack.FilterVars["host_name"] != "MyHost" || ack.FilterVars["service_name"] != "MyService" {
+			t.Errorf("Expected filter vars for MyHost/MyService got: %v", ack.FilterVars)
+		}
+
+		if !strings.Contains(ack.Comment, "#65012") {
+			t.Errorf("Expected comment with ticket number got: %s", ack.Comment)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results":[{"code":200.0,"status":"Successfully acknowledged problem for object 'MyHost!MyService'."}]}`))
+	}))
+
+	defer ts.Close()
+
+	rt := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, rt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := c.AcknowledgeProblem(ctx, "MyHost", "MyService", "notify_zammad", "Zammad Ticket #65012")
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+}
+
+func TestAcknowledgeProblemHost(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ack AcknowledgeRequest
+
+		_ = json.NewDecoder(r.Body).Decode(&ack)
+
+		if ack.Type != "Host" {
+			t.Errorf("Expected type Host got: %s", ack.Type)
+		}
+
+		if _, ok := ack.FilterVars["service_name"]; ok {
+			t.Errorf("Expected no service filter got: %v", ack.FilterVars)
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results":[{"code":200.0,"status":"Successfully acknowledged problem for object 'MyHost'."}]}`))
+	}))
+
+	defer ts.Close()
+
+	rt := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, rt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := c.AcknowledgeProblem(ctx, "MyHost", "", "notify_zammad", "Zammad Ticket #65012")
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+}
+
+func TestAcknowledgeProblemErrors(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":404.0,"status":"No objects found."}`))
+	}))
+
+	defer ts.Close()
+
+	rt := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, rt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err := c.AcknowledgeProblem(ctx, "MyHost", "", "notify_zammad", "")
+
+	if err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Errorf("Expected authentication error got: %v", err)
+	}
+
+	c = NewClient(*u, checkhttpconfig.NewBasicAuthRoundTripper("root", "icinga", rt))
+
+	err = c.AcknowledgeProblem(ctx, "NoSuchHost", "", "notify_zammad", "")
+
+	if err == nil || !strings.Contains(err.Error(), "No objects found") {
+		t.Errorf("Expected not found error got: %v", err)
+	}
+}
+
+func TestGetObjectState(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET request, got %s", r.Method)
+		}
+
+		switch r.URL.Path {
+		case "/v1/objects/services/MyHost!MyService":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"results":[{"attrs":{"state":0.0,"acknowledgement":0.0,"last_check_result":{"output":"OK - all fine"}},"name":"MyHost!MyService","type":"Service"}]}`))
+		case "/v1/objects/hosts/MyHost":
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"results":[{"attrs":{"state":1.0,"acknowledgement":1.0,"last_check_result":{"output":"CRITICAL - host unreachable"}},"name":"MyHost","type":"Host"}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":404.0,"status":"No objects found."}`))
+		}
+	}))
+
+	defer ts.Close()
+
+	rt := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	u, _ := url.Parse(ts.URL)
+
+	c := NewClient(*u, rt)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	state, err := c.GetObjectState(ctx, "MyHost", "MyService")
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	if !state.OK() || state.LastCheck.Output != "OK - all fine" {
+		t.Errorf("Expected service to be OK got: %v", state)
+	}
+
+	state, err = c.GetObjectState(ctx, "MyHost", "")
+
+	if err != nil {
+		t.Errorf("Did not expect error: %v", err)
+	}
+
+	if state.OK() {
+		t.Errorf("Expected host to be down got: %v", state)
+	}
+
+	_, err = c.GetObjectState(ctx, "NoSuchHost", "")
+
+	if !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Expected object not found error got: %v", err)
+	}
+}
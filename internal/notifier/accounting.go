@@ -0,0 +1,129 @@
+package notifier
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+)
+
+// timeComment matches the time spent in acknowledgement comments, e.g. #time 15
+var timeComment = regexp.MustCompile(`(?i)#time\s+(\d+(?:\.\d+)?)`)
+
+// problemStartLayouts are the accepted formats of the problem start besides Unix timestamps,
+// the second one is used by the $icinga.long_date_time$ macro
+var problemStartLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+}
+
+// now returns the current time, it is replaced in the tests
+var now = time.Now
+
+// TimeAccounting fills the accounted time of the articles.
+// Recovery articles account the duration of the outage,
+// Acknowledgement articles the minutes given in the comment as #time N.
+type TimeAccounting struct {
+	// Rounding rounds the time up to a multiple, e.g. 15 minutes
+	Rounding time.Duration
+	// Factor converts minutes into the time unit used in Zammad, e.g. 1/60 for hours
+	Factor float64
+	// Logger is optional, without it the default logger is used
+	Logger *slog.Logger
+}
+
+func (ta *TimeAccounting) logger() *slog.Logger {
+	if ta.Logger != nil {
+		return ta.Logger
+	}
+
+	return slog.Default()
+}
+
+// outage returns the accounted time from the start of the problem until now.
+// The problem start of the notification is preferred over the creation of the ticket,
+// unless it is after the creation, e.g. the time of the recovery passed by mistake.
+func (ta *TimeAccounting) outage(n Notification, ticket zammad.Ticket) (string, error) {
+	start := ticket.CreatedAt
+
+	if n.IcingaProblemStart != "" {
+		problemStart, err := parseProblemStart(n.IcingaProblemStart)
+
+		if err != nil {
+			return "", err
+		}
+
+		if ticket.CreatedAt.IsZero() || !problemStart.After(ticket.CreatedAt) {
+			start = problemStart
+		} else {
+			ta.logger().Warn("problem start is after the creation of the ticket, using the creation instead",
+				"problem_start", n.IcingaProblemStart, "ticket", ticket.Number)
+		}
+	}
+
+	if start.IsZero() {
+		return "", nil
+	}
+
+	return ta.format(now().Sub(start)), nil
+}
+
+// acknowledged returns the accounted time given in the comment,
+// it is empty if the comment does not contain #time N
+func (ta *TimeAccounting) acknowledged(n Notification) string {
+	m := timeComment.FindStringSubmatch(n.IcingaComment)
+
+	if m == nil {
+		return ""
+	}
+
+	minutes, _ := strconv.ParseFloat(m[1], 64)
+
+	return ta.format(time.Duration(minutes * float64(time.Minute)))
+}
+
+// format rounds the duration up, converts it with the factor and
+// returns it as expected by Zammad, durations below a minute are ignored
+func (ta *TimeAccounting) format(d time.Duration) string {
+	if d < time.Minute {
+		return ""
+	}
+
+	if ta.Rounding > 0 && d%ta.Rounding != 0 {
+		d = d.Truncate(ta.Rounding) + ta.Rounding
+	}
+
+	factor := ta.Factor
+
+	if factor == 0 {
+		factor = 1
+	}
+
+	units := math.Round(d.Minutes()*factor*100) / 100
+
+	return strconv.FormatFloat(units, 'f', -1, 64)
+}
+
+// parseProblemStart parses the start of the problem,
+// given as Unix timestamp like $service.previous_state_change$ or as date
+func parseProblemStart(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		sec, frac := math.Modf(seconds)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+
+	for _, layout := range problemStartLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid problem start '%s', expected a Unix timestamp or a date like %s", s, time.RFC3339)
+}
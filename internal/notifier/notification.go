@@ -0,0 +1,148 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NETWAYS/notify_zammad/internal/routing"
+)
+
+// Notification holds the data of a single notification,
+// the JSON fields are used when notifications are received via HTTP
+type Notification struct {
+	ZammadGroup            string `json:"zammad_group"`
+	ZammadCustomer         string `json:"zammad_customer"`
+	IcingaHostname         string `json:"host_name"`
+	IcingaServiceName      string `json:"service_name"`
+	IcingaCheckState       string `json:"check_state"`
+	IcingaCheckOutput      string `json:"check_output"`
+	IcingaNotificationType string `json:"notification_type"`
+	IcingaAuthor           string `json:"notification_author"`
+	IcingaComment          string `json:"notification_comment"`
+	IcingaDate             string `json:"notification_date"`
+
+	// IcingaProblemStart is the start of the problem, used for the time accounting of recoveries
+	IcingaProblemStart string `json:"problem_start"`
+
+	// Organization, owner, priority and tags of new tickets are optional
+	ZammadOrganization string   `json:"zammad_organization"`
+	ZammadOwner        string   `json:"zammad_owner"`
+	ZammadPriority     string   `json:"zammad_priority"`
+	ZammadTags         []string `json:"zammad_tags,omitempty"`
+
+	// ZammadAttributes are additional object manager attributes of the ticket, e.g. icinga_zone
+	ZammadAttributes map[string]string `json:"zammad_attributes,omitempty"`
+
+	// Host groups and custom variables of the object, used for the routing of tickets
+	IcingaHostGroups []string          `json:"host_groups,omitempty"`
+	IcingaVars       map[string]string `json:"vars,omitempty"`
+
+	// Details are additional key/value pairs rendered in the article
+	Details map[string]string `json:"details,omitempty"`
+
+	// merged marks service notifications added to the open ticket of their host
+	merged bool
+}
+
+// Validate checks if all required fields of the notification are set
+func (n *Notification) Validate() error {
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"notification_type", n.IcingaNotificationType},
+		{"host_name", n.IcingaHostname},
+		{"check_state", n.IcingaCheckState},
+		{"check_output", n.IcingaCheckOutput},
+		{"zammad_group", n.ZammadGroup},
+		{"zammad_customer", n.ZammadCustomer},
+	}
+
+	missing := make([]string, 0, len(required))
+
+	for _, r := range required {
+		if r.value == "" {
+			missing = append(missing, r.name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("required field(s) %s not set", strings.Join(missing, ", "))
+	}
+
+	return validateAttributes(n.ZammadAttributes)
+}
+
+// ReservedAttributes must not be set as attributes. The Icinga attributes are used to find the tickets,
+// the core fields of the ticket are set by the notifier, e.g. the owner by the assignment.
+var ReservedAttributes = []string{
+	"icinga_host", "icinga_service",
+	"id", "number", "title", "article", "article_ids",
+	"state", "state_id", "priority", "priority_id",
+	"group", "group_id", "customer", "customer_id",
+	"owner", "owner_id", "organization", "organization_id",
+	"created_at", "updated_at", "created_by_id", "updated_by_id",
+}
+
+// validateAttributes returns an error if one of the ReservedAttributes is set
+func validateAttributes(attrs map[string]string) error {
+	for _, name := range ReservedAttributes {
+		if _, ok := attrs[name]; ok {
+			return fmt.Errorf("attribute %s is reserved and cannot be set", name)
+		}
+	}
+
+	return nil
+}
+
+// Key returns the object the notification is about,
+// notifications with the same key affect the same ticket
+func (n *Notification) Key() string {
+	return n.IcingaHostname + "!" + n.IcingaServiceName
+}
+
+// Route sets the Zammad fields that are not set yet from the first rule
+// matching the notification and returns the name of the rule
+func (n *Notification) Route(rules *routing.Rules) string {
+	route, name := rules.Route(routing.Object{
+		Host:       n.IcingaHostname,
+		Service:    n.IcingaServiceName,
+		HostGroups: n.IcingaHostGroups,
+		Vars:       n.IcingaVars,
+	})
+
+	fields := []struct {
+		value *string
+		route string
+	}{
+		{&n.ZammadGroup, route.Group},
+		{&n.ZammadCustomer, route.Customer},
+		{&n.ZammadOrganization, route.Organization},
+		{&n.ZammadOwner, route.Owner},
+		{&n.ZammadPriority, route.Priority},
+	}
+
+	for _, f := range fields {
+		if *f.value == "" {
+			*f.value = f.route
+		}
+	}
+
+	if len(n.ZammadTags) == 0 {
+		n.ZammadTags = route.Tags
+	}
+
+	for k, v := range route.Attributes {
+		if _, ok := n.ZammadAttributes[k]; ok {
+			continue
+		}
+
+		if n.ZammadAttributes == nil {
+			n.ZammadAttributes = make(map[string]string, len(route.Attributes))
+		}
+
+		n.ZammadAttributes[k] = v
+	}
+
+	return name
+}
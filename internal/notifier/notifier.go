@@ -0,0 +1,863 @@
+// Package notifier implements the handling of Icinga notifications with Zammad tickets
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sort"
+	"strings"
+
+	"github.com/NETWAYS/go-icingadsl"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+	"github.com/NETWAYS/notify_zammad/internal/client"
+)
+
+// ErrUnsupportedNotificationType is returned for notification types the notifier can't handle
+var ErrUnsupportedNotificationType = errors.New("unsupported notification type. Currently supported: Problem/Recovery/Acknowledgement")
+
+// ErrNoRecoveryTicket is returned for Recovery notifications without open or new ticket,
+// e.g. the ticket was closed manually before
+var ErrNoRecoveryTicket = errors.New("no open or new ticket found to add recovery article to")
+
+// Acknowledger acknowledges problems in the monitoring system
+type Acknowledger interface {
+	AcknowledgeProblem(ctx context.Context, host, service, author, comment string) error
+}
+
+// Resolver resolves the references to Zammad users, groups and organizations to their IDs
+type Resolver interface {
+	Customer(ctx context.Context, ref string) (int, error)
+	Owner(ctx context.Context, ref string) (int, error)
+	Group(ctx context.Context, ref string) (int, error)
+	Organization(ctx context.Context, ref string) (int, error)
+}
+
+// UserResolver resolves the references to Zammad users to their IDs
+type UserResolver interface {
+	Owner(ctx context.Context, ref string) (int, error)
+}
+
+// RecipientResolver resolves the references to Zammad customers to their email addresses
+type RecipientResolver interface {
+	Email(ctx context.Context, ref string) (string, error)
+}
+
+// Assigner picks the owner of new tickets
+type Assigner interface {
+	Next(ctx context.Context) (string, error)
+}
+
+// Notifier handles notifications with the Zammad API
+type Notifier struct {
+	Client client.TicketService
+	// Logger logs the problems that do not fail the notification
+	Logger *slog.Logger
+	// Acknowledger is optional, if set the problem is acknowledged
+	// when a new ticket is created for it
+	Acknowledger Acknowledger
+	// AcknowledgeAuthor is the author of the acknowledgements
+	AcknowledgeAuthor string
+	// OnBehalfOfAuthor makes the articles of Acknowledgement and Custom notifications appear
+	// in Zammad as written by the notification author
+	OnBehalfOfAuthor bool
+	// Users is optional, if set the notification author is looked up before the articles
+	// are written on behalf of the author, unknown authors fall back to the API user
+	Users UserResolver
+	// Recipients is optional, if set the email articles without recipient
+	// are sent to the email address of the customer
+	Recipients RecipientResolver
+	// Resolver is optional, if set the group, customer, organization and owner of new tickets
+	// are validated and sent by their ID
+	Resolver Resolver
+	// Assigner is optional, if set it picks the owner of new tickets without owner
+	Assigner Assigner
+	// AssignAuthor reassigns the ticket to the Zammad user matching
+	// the author of Acknowledgement notifications
+	AssignAuthor bool
+	// UpdateAttributes sets the attributes of the notification on existing tickets as well
+	UpdateAttributes bool
+	// VarAttributes maps custom variables to ticket attributes, which are kept up to date on existing tickets
+	VarAttributes map[string]string
+	// VarDetails maps custom variables to the labels they are rendered with in the articles
+	VarDetails map[string]string
+	// TimeAccounting is optional, if set the time of Recovery and Acknowledgement articles is accounted
+	TimeAccounting *TimeAccounting
+	// LinkHostTickets links new service tickets to the open ticket of their host,
+	// and new host tickets to the open tickets of their services
+	LinkHostTickets bool
+	// MergeServiceProblems adds the service notifications as articles to the open ticket
+	// of their host instead of creating a ticket for each service
+	MergeServiceProblems bool
+	// Articles holds the ArticleOptions by the lower case notification type, e.g. recovery.
+	// DefaultArticleOptions are used for the other types.
+	Articles map[string]ArticleOptions
+}
+
+// New returns a Notifier using the given client
+func New(c client.TicketService) *Notifier {
+	return &Notifier{
+		Client: c,
+		Logger: slog.Default(),
+	}
+}
+
+// Result describes the actions taken for a notification
+type Result struct {
+	// Ticket is the ticket the notification was handled with,
+	// the ID is 0 if no ticket was found or created
+	Ticket zammad.Ticket
+	// Created reports if the ticket was created for the notification
+	Created bool
+	// ArticleAdded reports if an article was added to an existing ticket
+	ArticleAdded bool
+	// State is the state the ticket was set to, empty if unchanged
+	State zammad.TicketState
+	// Acknowledged reports if the problem was acknowledged
+	Acknowledged bool
+	// Owner is the user the ticket was assigned to, empty if unchanged
+	Owner string
+	// AttributesUpdated reports if the attributes of an existing ticket were updated
+	AttributesUpdated bool
+	// TimeAccounted is the time accounted with the article, empty if none
+	TimeAccounted string
+	// Linked is the number of related tickets the ticket was linked to
+	Linked int
+}
+
+// String returns a short summary of the actions taken
+func (r Result) String() string {
+	if r.Ticket.ID == 0 {
+		return "no ticket found, nothing to do"
+	}
+
+	var actions []string
+
+	if r.Created {
+		actions = append(actions, "created")
+	}
+
+	if r.ArticleAdded {
+		actions = append(actions, "article added")
+	}
+
+	if r.State != "" {
+		actions = append(actions, "state set to "+string(r.State))
+	}
+
+	if r.Owner != "" {
+		actions = append(actions, "assigned to "+r.Owner)
+	}
+
+	if r.AttributesUpdated {
+		actions = append(actions, "attributes updated")
+	}
+
+	if r.TimeAccounted != "" {
+		actions = append(actions, "time accounted "+r.TimeAccounted)
+	}
+
+	if r.Linked > 0 {
+		actions = append(actions, fmt.Sprintf("linked to %d related ticket(s)", r.Linked))
+	}
+
+	if r.Acknowledged {
+		actions = append(actions, "problem acknowledged")
+	}
+
+	name := fmt.Sprintf("Ticket %d", r.Ticket.ID)
+
+	if r.Ticket.Number != "" {
+		name = "Ticket #" + r.Ticket.Number
+	}
+
+	return name + ": " + strings.Join(actions, ", ")
+}
+
+// Process handles the given notification with the Zammad API
+func (nt *Notifier) Process(ctx context.Context, n Notification) (Result, error) {
+	notificationType, err := icingadsl.ParseNotificationType(n.IcingaNotificationType)
+
+	if err != nil {
+		return Result{}, ErrUnsupportedNotificationType
+	}
+
+	// Search for existing Tickets
+	tickets, err := nt.Client.SearchTickets(ctx, n.IcingaHostname, n.IcingaServiceName)
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	var ticket zammad.Ticket
+
+	// Using the first ticket found for the notification,
+	// the SearchTickets methods returns the tickets by created_at.
+	// If no ticket is found the zammad.Ticket type will be empty,
+	// which can be used to detect if a new ticket needs to be created.
+	// For host notifications the search also returns the service tickets of the host,
+	// with related tickets these are skipped so the host problem gets its own ticket.
+	related := nt.LinkHostTickets || nt.MergeServiceProblems
+
+	for _, t := range tickets {
+		if !related || t.IcingaService == n.IcingaServiceName {
+			ticket = t
+			break
+		}
+	}
+
+	// Service problems are added to the open host ticket while the host is down
+	if ticket.ID == 0 && n.IcingaServiceName != "" && nt.MergeServiceProblems &&
+		(notificationType == icingadsl.Problem || notificationType == icingadsl.Recovery || notificationType == icingadsl.Acknowledgement) {
+		ticket, err = nt.hostTicket(ctx, n.IcingaHostname)
+
+		if err != nil {
+			return Result{}, err
+		}
+
+		n.merged = ticket.ID != 0
+	}
+
+	synced := nt.mapVars(&n)
+
+	// The custom variables might be mapped to reserved attributes
+	err = validateAttributes(n.ZammadAttributes)
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	r, err := nt.handle(ctx, notificationType, n, ticket)
+
+	// The attributes of the host ticket are not changed by its services
+	if err != nil || ticket.ID == 0 || n.merged {
+		return r, err
+	}
+
+	// The attributes mapped from custom variables are always kept up to date
+	if nt.UpdateAttributes {
+		synced = n.ZammadAttributes
+	}
+
+	changed := changedAttributes(ticket, synced)
+
+	if len(changed) == 0 {
+		return r, nil
+	}
+
+	err = nt.updateAttributes(ctx, ticket, changed)
+	r.AttributesUpdated = err == nil
+
+	return r, err
+}
+
+// mapVars adds the custom variables mapped by VarAttributes and VarDetails
+// to the attributes and details of the notification and returns the mapped attributes.
+// Attributes and details set explicitly take precedence.
+func (nt *Notifier) mapVars(n *Notification) map[string]string {
+	if len(nt.VarAttributes) == 0 && len(nt.VarDetails) == 0 {
+		return nil
+	}
+
+	// The maps are copied since they might be shared with the caller
+	attrs := make(map[string]string, len(n.ZammadAttributes)+len(nt.VarAttributes))
+	details := make(map[string]string, len(n.Details)+len(nt.VarDetails))
+	synced := make(map[string]string, len(nt.VarAttributes))
+
+	maps.Copy(attrs, n.ZammadAttributes)
+	maps.Copy(details, n.Details)
+
+	for name, attr := range nt.VarAttributes {
+		value, ok := n.IcingaVars[name]
+
+		if !ok {
+			continue
+		}
+
+		if _, ok := attrs[attr]; !ok {
+			attrs[attr] = value
+		}
+
+		synced[attr] = attrs[attr]
+	}
+
+	for name, label := range nt.VarDetails {
+		value, ok := n.IcingaVars[name]
+
+		if _, exists := details[label]; ok && !exists {
+			details[label] = value
+		}
+	}
+
+	n.ZammadAttributes = attrs
+	n.Details = details
+
+	return synced
+}
+
+// changedAttributes returns the attributes that differ from the values of the ticket
+func changedAttributes(ticket zammad.Ticket, attrs map[string]string) map[string]string {
+	changed := make(map[string]string, len(attrs))
+
+	for k, v := range attrs {
+		if current, ok := ticket.Attributes[k]; !ok || fmt.Sprint(current) != v {
+			changed[k] = v
+		}
+	}
+
+	return changed
+}
+
+// handle dispatches the notification to the handler of its type
+func (nt *Notifier) handle(ctx context.Context, notificationType icingadsl.NotificationType, n Notification, ticket zammad.Ticket) (Result, error) {
+	switch notificationType {
+	case icingadsl.Custom:
+		// If ticket exists, adds article to existing ticket
+		return nt.handleCustomNotification(ctx, n, ticket, "Custom")
+	case icingadsl.Acknowledgement:
+		// If ticket exists, adds article to existing ticket
+		return nt.handleAcknowledgeNotification(ctx, n, ticket)
+	case icingadsl.Problem:
+		// Opens a new ticket if none exists
+		// If one exists, adds article to existing ticket
+		return nt.handleProblemNotification(ctx, n, ticket)
+	case icingadsl.Recovery:
+		// Closes a ticket if one exists
+		return nt.handleRecoveryNotification(ctx, n, ticket)
+	case icingadsl.DowntimeStart:
+		return nt.handleCustomNotification(ctx, n, ticket, "DowntimeStart")
+	case icingadsl.DowntimeEnd:
+		return nt.handleCustomNotification(ctx, n, ticket, "DowntimeEnd")
+	case icingadsl.DowntimeRemoved:
+		return nt.handleCustomNotification(ctx, n, ticket, "DowntimeRemoved")
+	case icingadsl.FlappingStart:
+		return nt.handleCustomNotification(ctx, n, ticket, "FlappingStart")
+	case icingadsl.FlappingEnd:
+		return nt.handleCustomNotification(ctx, n, ticket, "FlappingEnd")
+	default:
+		return Result{}, ErrUnsupportedNotificationType
+	}
+}
+
+// CreateArticleBody is a small util function to create an articles HTML body
+func CreateArticleBody(n Notification, header string) string {
+	var b strings.Builder
+
+	b.WriteString(fmt.Sprintf("<h3>%s</h3>", header))
+	b.WriteString(fmt.Sprintf("<p>Check State: %s</p>", n.IcingaCheckState))
+	b.WriteString(fmt.Sprintf("<p>Check Output: %s</p>", n.IcingaCheckOutput))
+
+	if n.IcingaAuthor != "" {
+		b.WriteString(fmt.Sprintf("<p>Notification Author: %s</p>", n.IcingaAuthor))
+	}
+
+	if n.IcingaDate != "" {
+		b.WriteString(fmt.Sprintf("<p>Notification Date: %s</p>", n.IcingaDate))
+	}
+
+	if n.IcingaComment != "" {
+		b.WriteString(fmt.Sprintf("<p>Notification Comment: %s</p>", n.IcingaComment))
+	}
+
+	// Sort the details to keep the articles stable
+	keys := make([]string, 0, len(n.Details))
+
+	for k := range n.Details {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		b.WriteString(fmt.Sprintf("<p>%s: %s</p>", k, n.Details[k]))
+	}
+
+	return b.String()
+}
+
+// ArticleOptions configure the articles added for a notification type
+type ArticleOptions struct {
+	// Type is the article type, e.g. web, note or email
+	Type string
+	// Sender is Agent, Customer or System
+	Sender string
+	// Internal articles are not visible to the customer
+	Internal bool
+	// To is the recipient of email articles, by default the customer is used if referenced by email
+	To string
+}
+
+// DefaultArticleOptions are used for the notification types without options
+var DefaultArticleOptions = ArticleOptions{
+	Type:     "web",
+	Sender:   "Agent",
+	Internal: true,
+}
+
+// newArticle returns an article for the given notification,
+// using the ArticleOptions of the notification type
+func (nt *Notifier) newArticle(n Notification, ticketID int, subject string) zammad.Article {
+	options, ok := nt.Articles[strings.ToLower(n.IcingaNotificationType)]
+
+	if !ok {
+		options = DefaultArticleOptions
+	}
+
+	// Articles of services on the ticket of their host name the service
+	if n.merged {
+		subject += " " + n.IcingaServiceName
+	}
+
+	a := zammad.Article{
+		TicketID:    ticketID,
+		Subject:     subject,
+		Body:        CreateArticleBody(n, subject),
+		ContentType: "text/html",
+		Type:        options.Type,
+		Internal:    options.Internal,
+		Sender:      options.Sender,
+	}
+
+	// Zammad sends email articles to the recipient,
+	// without one the email address of the customer is added later
+	if a.Type == "email" {
+		a.To = options.To
+	}
+
+	return a
+}
+
+// handleProblemNotification opens a new ticket if none exists,
+// If one exists, adds message to existing ticket.
+// If a new ticket is created and an Acknowledger is set, the problem is acknowledged.
+func (nt *Notifier) handleProblemNotification(ctx context.Context, n Notification, ticket zammad.Ticket) (Result, error) {
+	a := nt.newArticle(n, ticket.ID, "Problem")
+
+	// If a Zammad Ticket exists, add the article to this ticket.
+	if ticket.ID != 0 {
+		err := nt.addArticle(ctx, n, a)
+
+		return Result{Ticket: ticket, ArticleAdded: err == nil}, err
+	}
+
+	// Open a new Ticket with the given data
+	var title strings.Builder
+
+	title.WriteString("[Problem] ")
+
+	title.WriteString("State: " + n.IcingaCheckState + " for")
+	title.WriteString(" Host: " + n.IcingaHostname)
+
+	if n.IcingaServiceName != "" {
+		title.WriteString(" Service: " + n.IcingaServiceName)
+	}
+
+	newTicket := zammad.NewTicket{}
+
+	newTicket.Title = title.String()
+	newTicket.Group = n.ZammadGroup
+	newTicket.Customer = n.ZammadCustomer
+	newTicket.IcingaHost = n.IcingaHostname
+	newTicket.IcingaService = n.IcingaServiceName
+	newTicket.Owner = n.ZammadOwner
+	newTicket.Priority = n.ZammadPriority
+	newTicket.Organization = n.ZammadOrganization
+	newTicket.Attributes = attributes(n.ZammadAttributes)
+	newTicket.Article = a
+
+	err := nt.addRecipient(ctx, n, &newTicket.Article)
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	// An explicitly set owner takes precedence over the assignment
+	if newTicket.Owner == "" && nt.Assigner != nil {
+		owner, err := nt.Assigner.Next(ctx)
+
+		if err != nil {
+			return Result{}, fmt.Errorf("could not assign ticket: %w", err)
+		}
+
+		newTicket.Owner = owner
+	}
+
+	owner := newTicket.Owner
+
+	err = nt.resolve(ctx, &newTicket)
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	created, err := nt.Client.CreateTicket(ctx, newTicket)
+
+	if err != nil {
+		return Result{}, err
+	}
+
+	r := Result{Ticket: created, Created: true, Owner: owner}
+
+	for _, tag := range n.ZammadTags {
+		err = nt.Client.AddTag(ctx, created.ID, tag)
+
+		if err != nil {
+			return r, fmt.Errorf("ticket #%s created, but %w", created.Number, err)
+		}
+	}
+
+	if created.ID != 0 && nt.LinkHostTickets {
+		r.Linked, err = nt.linkRelatedTickets(ctx, n, created)
+
+		if err != nil {
+			return r, fmt.Errorf("ticket #%s created, but %w", created.Number, err)
+		}
+	}
+
+	// Acknowledge the problem in Icinga if a new ticket was created
+	if created.ID != 0 && nt.Acknowledger != nil {
+		err = nt.acknowledgeProblem(ctx, n, created)
+		r.Acknowledged = err == nil
+	}
+
+	return r, err
+}
+
+// resolve replaces the group, customer and organization of the ticket by their IDs
+func (nt *Notifier) resolve(ctx context.Context, ticket *zammad.NewTicket) error {
+	if nt.Resolver == nil {
+		return nil
+	}
+
+	groupID, err := nt.Resolver.Group(ctx, ticket.Group)
+
+	if err != nil {
+		return err
+	}
+
+	customerID, err := nt.Resolver.Customer(ctx, ticket.Customer)
+
+	if err != nil {
+		return err
+	}
+
+	// Unknown IDs, e.g. of customers created in dry-run mode, are sent by their name
+	if groupID != 0 {
+		ticket.GroupID = groupID
+		ticket.Group = ""
+	}
+
+	if customerID != 0 {
+		ticket.CustomerID = customerID
+		ticket.Customer = ""
+	}
+
+	if ticket.Organization != "" {
+		organizationID, err := nt.Resolver.Organization(ctx, ticket.Organization)
+
+		if err != nil {
+			return err
+		}
+
+		ticket.OrganizationID = organizationID
+		ticket.Organization = ""
+	}
+
+	if ticket.Owner != "" {
+		ownerID, err := nt.Resolver.Owner(ctx, ticket.Owner)
+
+		if err != nil {
+			return err
+		}
+
+		ticket.OwnerID = ownerID
+		ticket.Owner = ""
+	}
+
+	return nil
+}
+
+// acknowledgeProblem acknowledges the problem for the given ticket,
+// the comment references the Zammad ticket number and URL.
+func (nt *Notifier) acknowledgeProblem(ctx context.Context, n Notification, ticket zammad.Ticket) error {
+	comment := fmt.Sprintf("Zammad Ticket #%s created: %s", ticket.Number, nt.Client.TicketURL(ticket))
+
+	err := nt.Acknowledger.AcknowledgeProblem(ctx, n.IcingaHostname, n.IcingaServiceName, nt.AcknowledgeAuthor, comment)
+
+	if err != nil {
+		return fmt.Errorf("ticket #%s created, but %w", ticket.Number, err)
+	}
+
+	return nil
+}
+
+// handleAcknowledgeNotification adds a new article to an existing ticket
+// If the ticket is in state new, it will be set to state open
+// If AssignAuthor is set, the ticket is assigned to the author
+// If no ticket exists an error is returned
+func (nt *Notifier) handleAcknowledgeNotification(ctx context.Context, n Notification, ticket zammad.Ticket) (Result, error) {
+	// If no Zammad Ticket exists, we cannot add an article and thus return an error
+	// and notify the user
+	if ticket.ID == 0 {
+		return Result{}, errors.New("no open or new ticket found to add acknowledgement article to")
+	}
+
+	a := nt.newArticle(n, ticket.ID, "Acknowledgement")
+
+	if nt.TimeAccounting != nil {
+		a.TimeUnit = nt.TimeAccounting.acknowledged(n)
+	}
+
+	r, err := nt.addArticleAndSetState(ctx, n, ticket, a, zammad.OpenTicketState)
+
+	// The host ticket is not reassigned by the acknowledgements of its services
+	if err != nil || !nt.AssignAuthor || n.IcingaAuthor == "" || n.merged {
+		return r, err
+	}
+
+	err = nt.assign(ctx, ticket, n.IcingaAuthor)
+
+	if err != nil {
+		return r, fmt.Errorf("acknowledgement added, but %w", err)
+	}
+
+	r.Owner = n.IcingaAuthor
+
+	return r, nil
+}
+
+// assign sets the owner of the ticket, the owner is sent by its ID if a Resolver is set
+func (nt *Notifier) assign(ctx context.Context, ticket zammad.Ticket, owner string) error {
+	fields := map[string]any{"owner": owner}
+
+	if nt.Resolver != nil {
+		ownerID, err := nt.Resolver.Owner(ctx, owner)
+
+		if err != nil {
+			return err
+		}
+
+		fields = map[string]any{"owner_id": ownerID}
+	}
+
+	_, err := nt.Client.UpdateTicket(ctx, ticket.ID, fields)
+
+	if err != nil {
+		return fmt.Errorf("could not assign ticket to %s: %w", owner, err)
+	}
+
+	return nil
+}
+
+// updateAttributes sets the attributes of an existing ticket
+func (nt *Notifier) updateAttributes(ctx context.Context, ticket zammad.Ticket, attrs map[string]string) error {
+	_, err := nt.Client.UpdateTicket(ctx, ticket.ID, attributes(attrs))
+
+	if err != nil {
+		return fmt.Errorf("could not update attributes of ticket #%s: %w", ticket.Number, err)
+	}
+
+	return nil
+}
+
+// attributes converts the attributes of a notification for the Zammad API
+func attributes(attrs map[string]string) map[string]any {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	m := make(map[string]any, len(attrs))
+
+	for k, v := range attrs {
+		m[k] = v
+	}
+
+	return m
+}
+
+// handleRecoveryNotification adds an article to an existing ticket and sets the state to closed
+// If TimeAccounting is set, the duration of the outage is accounted
+// If no ticket exists an error is returned
+func (nt *Notifier) handleRecoveryNotification(ctx context.Context, n Notification, ticket zammad.Ticket) (Result, error) {
+	if ticket.ID == 0 {
+		return Result{}, ErrNoRecoveryTicket
+	}
+
+	a := nt.newArticle(n, ticket.ID, "Recovery")
+
+	// The outage of merged services is accounted by the recovery of the host
+	if nt.TimeAccounting != nil && !n.merged {
+		timeUnit, err := nt.TimeAccounting.outage(n, ticket)
+
+		if err != nil {
+			return Result{}, err
+		}
+
+		a.TimeUnit = timeUnit
+	}
+
+	return nt.addArticleAndSetState(ctx, n, ticket, a, zammad.ClosedTicketState)
+}
+
+// hostTicket returns the open ticket of the host, the ID is 0 if none exists
+func (nt *Notifier) hostTicket(ctx context.Context, hostname string) (zammad.Ticket, error) {
+	tickets, err := nt.Client.SearchTickets(ctx, hostname, "")
+
+	if err != nil {
+		return zammad.Ticket{}, err
+	}
+
+	for _, t := range tickets {
+		if t.IcingaService == "" {
+			return t, nil
+		}
+	}
+
+	return zammad.Ticket{}, nil
+}
+
+// linkRelatedTickets links a new service ticket as child to the open ticket of its host,
+// or the open tickets of the services as children to a new host ticket.
+// It returns the number of linked tickets.
+func (nt *Notifier) linkRelatedTickets(ctx context.Context, n Notification, created zammad.Ticket) (int, error) {
+	tickets, err := nt.Client.SearchTickets(ctx, n.IcingaHostname, "")
+
+	if err != nil {
+		return 0, err
+	}
+
+	linked := 0
+
+	for _, t := range tickets {
+		switch {
+		case t.ID == created.ID:
+			continue
+		case n.IcingaServiceName == "" && t.IcingaService != "":
+			err = nt.Client.LinkTickets(ctx, t, created, zammad.ChildLinkType)
+		case n.IcingaServiceName != "" && t.IcingaService == "":
+			err = nt.Client.LinkTickets(ctx, created, t, zammad.ChildLinkType)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return linked, err
+		}
+
+		linked++
+	}
+
+	return linked, nil
+}
+
+// handleCustomNotification adds an article to an existing ticket
+// If no ticket exists nothing happens and the function returns
+func (nt *Notifier) handleCustomNotification(ctx context.Context, n Notification, ticket zammad.Ticket, notificationType string) (Result, error) {
+	if ticket.ID == 0 {
+		return Result{}, nil
+	}
+
+	err := nt.addArticle(ctx, n, nt.newArticle(n, ticket.ID, notificationType))
+
+	return Result{Ticket: ticket, ArticleAdded: err == nil}, err
+}
+
+// addArticle adds the article to the ticket. If OnBehalfOfAuthor is set, the articles of
+// Acknowledgement and Custom notifications are written as the notification author,
+// the ticket changes are still made by the API user.
+func (nt *Notifier) addArticle(ctx context.Context, n Notification, a zammad.Article) error {
+	err := nt.addRecipient(ctx, n, &a)
+
+	if err != nil {
+		return err
+	}
+
+	return nt.Client.AddArticleToTicket(nt.authorContext(ctx, n), a)
+}
+
+// addRecipient sets the recipient of email articles without one
+// to the email address of the customer
+func (nt *Notifier) addRecipient(ctx context.Context, n Notification, a *zammad.Article) error {
+	if a.Type != "email" || a.To != "" {
+		return nil
+	}
+
+	if strings.Contains(n.ZammadCustomer, "@") {
+		a.To = n.ZammadCustomer
+		return nil
+	}
+
+	if nt.Recipients == nil || n.ZammadCustomer == "" {
+		return errors.New("no recipient for the email article, the customer is not an email address")
+	}
+
+	to, err := nt.Recipients.Email(ctx, n.ZammadCustomer)
+
+	if err != nil {
+		return fmt.Errorf("could not find recipient of the email article: %w", err)
+	}
+
+	a.To = to
+
+	return nil
+}
+
+// authorContext returns the context to write the article of the notification with
+func (nt *Notifier) authorContext(ctx context.Context, n Notification) context.Context {
+	if !nt.OnBehalfOfAuthor || n.IcingaAuthor == "" {
+		return ctx
+	}
+
+	notificationType, _ := icingadsl.ParseNotificationType(n.IcingaNotificationType)
+
+	if notificationType != icingadsl.Acknowledgement && notificationType != icingadsl.Custom {
+		return ctx
+	}
+
+	// The author is an operator in Icinga, who is expected to have the same login in Zammad.
+	// Zammad rejects requests on behalf of unknown users, so the API user is used for them.
+	if nt.Users != nil {
+		_, err := nt.Users.Owner(ctx, n.IcingaAuthor)
+
+		if err != nil {
+			nt.Logger.Warn("notification author not found in Zammad, adding the article as API user",
+				"author", n.IcingaAuthor, "error", err)
+
+			return ctx
+		}
+	}
+
+	return client.WithOnBehalfOf(ctx, n.IcingaAuthor)
+}
+
+// addArticleAndSetState adds the article to the ticket and updates the ticket state
+func (nt *Notifier) addArticleAndSetState(ctx context.Context, n Notification, ticket zammad.Ticket, a zammad.Article, state zammad.TicketState) (Result, error) {
+	r := Result{Ticket: ticket}
+
+	err := nt.addArticle(ctx, n, a)
+
+	if err != nil {
+		return r, err
+	}
+
+	r.ArticleAdded = true
+	r.TimeAccounted = a.TimeUnit
+
+	// The state of the host ticket is not changed by its services
+	if n.merged {
+		return r, nil
+	}
+
+	err = nt.Client.UpdateTicketState(ctx, ticket, state)
+
+	if err != nil {
+		return r, err
+	}
+
+	r.State = state
+
+	return r, nil
+}
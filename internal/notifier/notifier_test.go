@@ -0,0 +1,1005 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NETWAYS/notify_zammad/internal/client"
+	"github.com/NETWAYS/notify_zammad/internal/resolve"
+	"github.com/NETWAYS/notify_zammad/internal/routing"
+	"github.com/NETWAYS/notify_zammad/internal/zammadtest"
+)
+
+type fakeAcknowledger struct {
+	calls []string
+	err   error
+}
+
+func (f *fakeAcknowledger) AcknowledgeProblem(_ context.Context, host, service, author, comment string) error {
+	f.calls = append(f.calls, strings.Join([]string{host, service, author, comment}, "|"))
+	return f.err
+}
+
+func newTestNotifier(t *testing.T) (*Notifier, *zammadtest.Server) {
+	t.Helper()
+
+	s := zammadtest.NewServer()
+	t.Cleanup(s.Close)
+
+	u, _ := url.Parse(s.URL)
+
+	return New(client.NewClient(*u, http.DefaultTransport)), s
+}
+
+func testNotification() Notification {
+	return Notification{
+		ZammadGroup:       "Users",
+		ZammadCustomer:    "jon.snow@zammad",
+		IcingaHostname:    "Host01",
+		IcingaServiceName: "hostalive",
+		IcingaCheckState:  "Down",
+		IcingaCheckOutput: "CRITICAL - host unreachable",
+	}
+}
+
+func TestCreateArticleBody(t *testing.T) {
+	actual := CreateArticleBody(Notification{}, "foo")
+	expected := "<h3>foo</h3>"
+
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+
+	expected = "<p>Check State: </p>"
+	if !strings.Contains(actual, expected) {
+		t.Error("\nActual: ", actual, "\nExpected: ", expected)
+	}
+}
+
+func TestNotifier_Lifecycle(t *testing.T) {
+	nt, s := newTestNotifier(t)
+
+	n := testNotification()
+
+	steps := []struct {
+		notificationType string
+		tickets          int
+		state            string
+		articles         int
+		result           string
+	}{
+		{"Problem", 1, "new", 1, "Ticket #65001: created"},
+		{"Problem", 1, "new", 2, "Ticket #65001: article added"},
+		{"Acknowledgement", 1, "open", 3, "Ticket #65001: article added, state set to open"},
+		{"DowntimeStart", 1, "open", 4, "Ticket #65001: article added"},
+		{"Recovery", 1, "closed", 5, "Ticket #65001: article added, state set to closed"},
+		{"Problem", 2, "new", 1, "Ticket #65007: created"},
+	}
+
+	for _, step := range steps {
+		n.IcingaNotificationType = step.notificationType
+
+		r, err := nt.Process(context.Background(), n)
+
+		if err != nil {
+			t.Fatalf("%s: Did not expect error: %v", step.notificationType, err)
+		}
+
+		if r.String() != step.result {
+			t.Errorf("%s: \nActual: %s\nExpected: %s", step.notificationType, r, step.result)
+		}
+
+		tickets := s.Tickets()
+
+		if len(tickets) != step.tickets {
+			t.Fatalf("%s: Expected %d tickets got: %v", step.notificationType, step.tickets, tickets)
+		}
+
+		last := tickets[len(tickets)-1]
+
+		if last.State() != step.state || len(s.Articles(last.ID())) != step.articles {
+			t.Errorf("%s: Expected state %s with %d articles got: %v %v",
+				step.notificationType, step.state, step.articles, last, s.Articles(last.ID()))
+		}
+	}
+}
+
+func TestNotifier_WithoutTicket(t *testing.T) {
+	nt, s := newTestNotifier(t)
+
+	n := testNotification()
+
+	for _, nType := range []string{"Acknowledgement", "Recovery"} {
+		n.IcingaNotificationType = nType
+
+		_, err := nt.Process(context.Background(), n)
+
+		if err == nil || !strings.Contains(err.Error(), "no open or new ticket found") {
+			t.Errorf("%s: Expected error got: %v", nType, err)
+		}
+	}
+
+	n.IcingaNotificationType = "Custom"
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil || r.Ticket.ID != 0 || len(s.Tickets()) != 0 {
+		t.Errorf("Expected Custom notification to be ignored got: %v %v", r, err)
+	}
+
+	n.IcingaNotificationType = "NoSuchType"
+
+	_, err = nt.Process(context.Background(), n)
+
+	if !errors.Is(err, ErrUnsupportedNotificationType) {
+		t.Errorf("Expected unsupported type error got: %v", err)
+	}
+}
+
+func TestNotifier_Acknowledge(t *testing.T) {
+	nt, _ := newTestNotifier(t)
+
+	ack := &fakeAcknowledger{}
+	nt.Acknowledger = ack
+	nt.AcknowledgeAuthor = "notify_zammad"
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil || !r.Acknowledged {
+		t.Fatalf("Expected acknowledged problem got: %v %v", r, err)
+	}
+
+	// The existing ticket is not acknowledged again
+	_, _ = nt.Process(context.Background(), n)
+
+	if len(ack.calls) != 1 {
+		t.Fatalf("Expected one acknowledgement got: %v", ack.calls)
+	}
+
+	if !strings.HasPrefix(ack.calls[0], "Host01|hostalive|notify_zammad|Zammad Ticket #65001 created: http://") {
+		t.Errorf("Unexpected acknowledgement: %s", ack.calls[0])
+	}
+
+	ack.err = errors.New("acknowledgement failed")
+	n.IcingaServiceName = "disk"
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err == nil || !r.Created || r.Acknowledged {
+		t.Errorf("Expected created ticket with acknowledgement error got: %v %v", r, err)
+	}
+}
+
+func TestNotification_Validate(t *testing.T) {
+	n := testNotification()
+
+	err := n.Validate()
+
+	if err == nil || err.Error() != "required field(s) notification_type not set" {
+		t.Errorf("Expected missing notification_type got: %v", err)
+	}
+
+	if n.Key() != "Host01!hostalive" {
+		t.Errorf("Unexpected key: %s", n.Key())
+	}
+
+	n.IcingaNotificationType = "Problem"
+	n.ZammadAttributes = map[string]string{"icinga_host": "Host02"}
+
+	err = n.Validate()
+
+	if err == nil || !strings.Contains(err.Error(), "attribute icinga_host is reserved") {
+		t.Errorf("Expected reserved attribute error got: %v", err)
+	}
+
+	// The core fields of the ticket cannot be changed by attributes
+	for _, name := range []string{"state", "owner_id", "group", "title", "customer_id", "priority"} {
+		n.ZammadAttributes = map[string]string{name: "foo"}
+
+		err = n.Validate()
+
+		if err == nil || !strings.Contains(err.Error(), "attribute "+name+" is reserved") {
+			t.Errorf("Expected reserved attribute error for %s got: %v", name, err)
+		}
+	}
+}
+
+func TestNotifier_OnBehalfOfAuthor(t *testing.T) {
+	nt, s := newTestNotifier(t)
+
+	nt.OnBehalfOfAuthor = true
+	nt.Users = resolve.New(nt.Client.(*client.Client))
+
+	s.AddUser(zammadtest.User{"login": "jdoe", "email": "jdoe@example.com"})
+
+	n := testNotification()
+	n.IcingaAuthor = "jdoe"
+
+	for _, nType := range []string{"Problem", "Acknowledgement", "Recovery"} {
+		n.IcingaNotificationType = nType
+
+		_, err := nt.Process(context.Background(), n)
+
+		if err != nil {
+			t.Fatalf("%s: Did not expect error: %v", nType, err)
+		}
+
+		// The ticket state is changed by the API user
+		if updatedBy := s.Tickets()[0].String("updated_by"); updatedBy != "" {
+			t.Errorf("%s: Expected ticket updated by the API user got: %s", nType, updatedBy)
+		}
+	}
+
+	ticket := s.Tickets()[0]
+	articles := s.Articles(ticket.ID())
+
+	// Only the acknowledgement is written by the author
+	for i, expected := range []any{nil, "jdoe", nil} {
+		if articles[i]["created_by"] != expected {
+			t.Errorf("Expected article %d created by %v got: %v", i, expected, articles[i])
+		}
+	}
+
+	// The author does not exist in Zammad, the API user is used instead
+	var logs bytes.Buffer
+
+	nt.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	n.IcingaAuthor = "icingaadmin"
+	n.IcingaNotificationType = "Problem"
+
+	_, _ = nt.Process(context.Background(), n)
+
+	n.IcingaNotificationType = "Acknowledgement"
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil || !r.ArticleAdded || r.State != "open" {
+		t.Fatalf("Expected acknowledgement by the API user got: %s %v", r, err)
+	}
+
+	ticket = s.Tickets()[1]
+	articles = s.Articles(ticket.ID())
+
+	if ticket.State() != "open" || len(articles) != 2 || articles[1]["created_by"] != nil {
+		t.Errorf("Expected acknowledgement article by the API user got: %v %v", ticket, articles)
+	}
+
+	if !strings.Contains(logs.String(), "notification author not found in Zammad") {
+		t.Errorf("Expected warning in the log of the notifier got: %s", logs.String())
+	}
+}
+
+func TestNotifier_Route(t *testing.T) {
+	nt, s := newTestNotifier(t)
+
+	rules, err := routing.Parse(strings.NewReader(`{
+  "rules": [
+    {"match": {"host_group": "databases"}, "group": "DBA", "owner": "jon.snow", "priority": "3 high", "tags": ["database", "icinga"]}
+  ],
+  "default": {"group": "Users", "customer": "monitoring@zammad"}
+}`))
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	n := testNotification()
+	n.ZammadGroup = ""
+	n.IcingaHostGroups = []string{"linux", "databases"}
+	n.IcingaNotificationType = "Problem"
+
+	rule := n.Route(rules)
+
+	// The customer set explicitly is kept
+	if rule != "rule 1" || n.ZammadGroup != "DBA" || n.ZammadCustomer != "jon.snow@zammad" {
+		t.Fatalf("Expected notification routed by rule 1 got: %s %v", rule, n)
+	}
+
+	_, err = nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	tickets := s.Tickets()
+
+	if len(tickets) != 1 || tickets[0].String("group") != "DBA" || tickets[0].String("owner") != "jon.snow" || tickets[0].String("priority") != "3 high" {
+		t.Errorf("Expected routed ticket got: %v", tickets)
+	}
+
+	if tags := s.Tags(tickets[0].ID()); strings.Join(tags, ",") != "database,icinga" {
+		t.Errorf("Expected tags got: %v", tags)
+	}
+}
+
+func TestNotifier_Resolve(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.Resolver = resolve.New(nt.Client.(*client.Client))
+
+	group := s.AddGroup(zammadtest.Group{"name": "Users"})
+	customer := s.AddUser(zammadtest.User{"login": "jon.snow", "email": "jon.snow@zammad"})
+	organization := s.AddOrganization(zammadtest.Organization{"name": "ACME"})
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+	n.ZammadOrganization = "ACME"
+
+	_, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	tickets := s.Tickets()
+
+	if len(tickets) != 1 || tickets[0].String("group_id") != fmt.Sprint(group["id"]) || tickets[0].String("customer_id") != fmt.Sprint(customer["id"]) {
+		t.Fatalf("Expected ticket with resolved IDs got: %v", tickets)
+	}
+
+	if tickets[0].String("organization_id") != fmt.Sprint(organization["id"]) {
+		t.Errorf("Expected ticket with organization got: %v", tickets[0])
+	}
+
+	// Unknown references fail before the ticket is created
+	n.IcingaHostname = "Host02"
+	n.ZammadGroup = "Admins"
+
+	_, err = nt.Process(context.Background(), n)
+
+	if !errors.Is(err, resolve.ErrNotFound) || len(s.Tickets()) != 1 {
+		t.Errorf("Expected not found error got: %v", err)
+	}
+}
+
+type fakeAssigner struct {
+	agent string
+	err   error
+}
+
+func (f *fakeAssigner) Next(_ context.Context) (string, error) {
+	return f.agent, f.err
+}
+
+func TestNotifier_Assign(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.Resolver = resolve.New(nt.Client.(*client.Client))
+	nt.Assigner = &fakeAssigner{agent: "arya.stark"}
+
+	s.AddGroup(zammadtest.Group{"name": "Users"})
+	s.AddUser(zammadtest.User{"login": "jon.snow", "email": "jon.snow@zammad"})
+	arya := s.AddUser(zammadtest.User{"login": "arya.stark"})
+	bran := s.AddUser(zammadtest.User{"login": "bran.stark"})
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if r.Owner != "arya.stark" || !strings.Contains(r.String(), "assigned to arya.stark") {
+		t.Errorf("Expected ticket assigned to arya.stark got: %s", r)
+	}
+
+	// An explicit owner is not overridden by the assignment
+	n.IcingaHostname = "Host02"
+	n.ZammadOwner = "bran.stark"
+
+	_, err = nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	tickets := s.Tickets()
+
+	if len(tickets) != 2 || tickets[0].String("owner_id") != fmt.Sprint(arya["id"]) || tickets[1].String("owner_id") != fmt.Sprint(bran["id"]) {
+		t.Errorf("Expected tickets with owners got: %v", tickets)
+	}
+
+	nt.Assigner = &fakeAssigner{err: errors.New("no agents")}
+	n.IcingaHostname = "Host03"
+	n.ZammadOwner = ""
+
+	_, err = nt.Process(context.Background(), n)
+
+	if err == nil || !strings.Contains(err.Error(), "could not assign ticket: no agents") {
+		t.Errorf("Expected assignment error got: %v", err)
+	}
+}
+
+func TestNotifier_AssignAuthor(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.AssignAuthor = true
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+
+	_, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	n.IcingaNotificationType = "Acknowledgement"
+	n.IcingaAuthor = "jon.snow"
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if !strings.Contains(r.String(), "assigned to jon.snow") || s.Tickets()[0].String("owner") != "jon.snow" {
+		t.Errorf("Expected ticket assigned to jon.snow got: %s %v", r, s.Tickets())
+	}
+
+	// With a Resolver the author must exist in Zammad
+	nt.Resolver = resolve.New(nt.Client.(*client.Client))
+	n.IcingaAuthor = "arya.stark"
+
+	r, err = nt.Process(context.Background(), n)
+
+	if !errors.Is(err, resolve.ErrNotFound) || !strings.Contains(err.Error(), "acknowledgement added, but owner 'arya.stark'") {
+		t.Errorf("Expected not found error got: %s %v", r, err)
+	}
+}
+
+func TestNotifier_Attributes(t *testing.T) {
+	nt, s := newTestNotifier(t)
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+	n.ZammadAttributes = map[string]string{"icinga_zone": "master", "environment": "production"}
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	ticket := s.Tickets()[0]
+
+	if ticket.String("icinga_zone") != "master" || ticket.String("environment") != "production" {
+		t.Errorf("Expected ticket with attributes got: %v", ticket)
+	}
+
+	if r.AttributesUpdated {
+		t.Errorf("Expected no update of new ticket got: %s", r)
+	}
+
+	// Existing tickets are only updated if enabled
+	n.ZammadAttributes = map[string]string{"icinga_zone": "satellite"}
+
+	_, _ = nt.Process(context.Background(), n)
+
+	if s.Tickets()[0].String("icinga_zone") != "master" {
+		t.Errorf("Expected unchanged attributes got: %v", s.Tickets()[0])
+	}
+
+	nt.UpdateAttributes = true
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if !strings.Contains(r.String(), "attributes updated") || s.Tickets()[0].String("icinga_zone") != "satellite" {
+		t.Errorf("Expected updated attributes got: %s %v", r, s.Tickets()[0])
+	}
+}
+
+func TestNotifier_VarMapping(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.VarAttributes = map[string]string{"location": "icinga_location", "sla_level": "sla_level"}
+	nt.VarDetails = map[string]string{"contract_id": "Contract"}
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+	n.IcingaVars = map[string]string{"location": "Nuremberg", "contract_id": "C-4711", "sla_level": "gold"}
+	// Attributes set explicitly take precedence
+	n.ZammadAttributes = map[string]string{"sla_level": "platinum"}
+
+	_, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	ticket := s.Tickets()[0]
+
+	if ticket.String("icinga_location") != "Nuremberg" || ticket.String("sla_level") != "platinum" {
+		t.Errorf("Expected ticket with mapped attributes got: %v", ticket)
+	}
+
+	articles := s.Articles(ticket.ID())
+
+	if len(articles) != 1 || !strings.Contains(fmt.Sprint(articles[0]["body"]), "<p>Contract: C-4711</p>") {
+		t.Errorf("Expected article with mapped detail got: %v", articles)
+	}
+
+	// The caller's attributes are not changed
+	if len(n.ZammadAttributes) != 1 {
+		t.Errorf("Expected unchanged attributes of the notification got: %v", n.ZammadAttributes)
+	}
+
+	// Unchanged values are not updated
+	r, _ := nt.Process(context.Background(), n)
+
+	if r.AttributesUpdated {
+		t.Errorf("Expected no update of unchanged attributes got: %s", r)
+	}
+
+	n.IcingaVars["location"] = "Berlin"
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err != nil || !r.AttributesUpdated {
+		t.Fatalf("Expected updated attributes got: %s %v", r, err)
+	}
+
+	if s.Tickets()[0].String("icinga_location") != "Berlin" {
+		t.Errorf("Expected updated location got: %v", s.Tickets()[0])
+	}
+
+	// Reserved attributes cannot be set by custom variables either
+	nt.VarAttributes = map[string]string{"location": "icinga_host"}
+
+	_, err = nt.Process(context.Background(), n)
+
+	if err == nil || !strings.Contains(err.Error(), "attribute icinga_host is reserved") {
+		t.Errorf("Expected reserved attribute error got: %v", err)
+	}
+
+	if s.Tickets()[0].String("icinga_host") != n.IcingaHostname {
+		t.Errorf("Expected unchanged host of the ticket got: %v", s.Tickets()[0])
+	}
+}
+
+func TestTimeAccounting_Format(t *testing.T) {
+	testcases := map[string]struct {
+		ta       TimeAccounting
+		duration time.Duration
+		expected string
+	}{
+		"minutes":         {TimeAccounting{}, 42*time.Minute + 10*time.Second, "42.17"},
+		"below-minute":    {TimeAccounting{}, 30 * time.Second, ""},
+		"rounded-up":      {TimeAccounting{Rounding: 15 * time.Minute}, 16 * time.Minute, "30"},
+		"already-rounded": {TimeAccounting{Rounding: 15 * time.Minute}, 30 * time.Minute, "30"},
+		"hours":           {TimeAccounting{Rounding: 15 * time.Minute, Factor: 1.0 / 60}, 50 * time.Minute, "1"},
+		"quarter-hours":   {TimeAccounting{Rounding: 15 * time.Minute, Factor: 1.0 / 15}, 95 * time.Minute, "7"},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			if actual := tc.ta.format(tc.duration); actual != tc.expected {
+				t.Errorf("Expected %q got: %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestParseProblemStart(t *testing.T) {
+	expected := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	for _, s := range []string{"1714557600", "1714557600.000", "2024-05-01T12:00:00+02:00", "2024-05-01 12:00:00 +0200"} {
+		actual, err := parseProblemStart(s)
+
+		if err != nil || !actual.Equal(expected) {
+			t.Errorf("Expected %s for %s got: %s %v", expected, s, actual, err)
+		}
+	}
+
+	_, err := parseProblemStart("yesterday")
+
+	if err == nil || !strings.Contains(err.Error(), "invalid problem start 'yesterday'") {
+		t.Errorf("Expected invalid problem start error got: %v", err)
+	}
+}
+
+func TestNotifier_TimeAccounting(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.TimeAccounting = &TimeAccounting{Rounding: 15 * time.Minute}
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+
+	_, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	ticket := s.Tickets()[0]
+
+	n.IcingaNotificationType = "Acknowledgement"
+	n.IcingaComment = "Replaced the switch #time 20"
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil || r.TimeAccounted != "30" {
+		t.Errorf("Expected accounted time from the comment got: %s %v", r, err)
+	}
+
+	// The outage is accounted from the creation of the ticket
+	defer func() { now = time.Now }()
+
+	now = func() time.Time { return time.Now().Add(70 * time.Minute) }
+
+	n.IcingaNotificationType = "Recovery"
+	n.IcingaComment = ""
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err != nil || !strings.Contains(r.String(), "time accounted 75") {
+		t.Errorf("Expected accounted outage got: %s %v", r, err)
+	}
+
+	articles := s.Articles(ticket.ID())
+
+	if len(articles) != 3 || articles[1]["time_unit"] != "30" || articles[2]["time_unit"] != "75" {
+		t.Errorf("Expected articles with accounted time got: %v", articles)
+	}
+
+	// The problem start of the notification is preferred
+	n.IcingaNotificationType = "Problem"
+	n.IcingaHostname = "Host02"
+
+	_, _ = nt.Process(context.Background(), n)
+
+	n.IcingaNotificationType = "Recovery"
+	n.IcingaProblemStart = strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err != nil || r.TimeAccounted != "195" {
+		t.Errorf("Expected accounted outage from the problem start got: %s %v", r, err)
+	}
+
+	// The time of the recovery, e.g. $service.last_state_change$, is not the problem start
+	var logs bytes.Buffer
+
+	nt.TimeAccounting.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	n.IcingaNotificationType = "Problem"
+	n.IcingaHostname = "Host03"
+	n.IcingaProblemStart = ""
+
+	_, _ = nt.Process(context.Background(), n)
+
+	n.IcingaNotificationType = "Recovery"
+	n.IcingaProblemStart = strconv.FormatInt(now().Unix(), 10)
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err != nil || r.TimeAccounted != "75" {
+		t.Errorf("Expected accounted outage from the creation of the ticket got: %s %v", r, err)
+	}
+
+	if !strings.Contains(logs.String(), "problem start is after the creation of the ticket") {
+		t.Errorf("Expected warning in the log of the time accounting got: %s", logs.String())
+	}
+}
+
+func TestNotifier_LinkHostTickets(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.LinkHostTickets = true
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+	n.IcingaServiceName = "disk"
+	n.IcingaCheckState = "Critical"
+
+	// The service ticket is opened before the host goes down
+	_, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	n.IcingaServiceName = ""
+	n.IcingaCheckState = "Down"
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil || !r.Created || r.Linked != 1 {
+		t.Fatalf("Expected new host ticket linked to the service ticket got: %s %v", r, err)
+	}
+
+	n.IcingaServiceName = "http"
+	n.IcingaCheckState = "Critical"
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err != nil || !strings.Contains(r.String(), "linked to 1 related ticket(s)") {
+		t.Fatalf("Expected new service ticket linked to the host ticket got: %s %v", r, err)
+	}
+
+	tickets := s.Tickets()
+	links := s.Links()
+
+	if len(links) != 2 {
+		t.Fatalf("Expected two links got: %v", links)
+	}
+
+	for i, service := range []zammadtest.Ticket{tickets[0], tickets[2]} {
+		if links[i].SourceID != service.ID() || links[i].TargetID != tickets[1].ID() || links[i].Type != "child" {
+			t.Errorf("Expected service ticket %d as child of the host ticket got: %v", service.ID(), links[i])
+		}
+	}
+}
+
+func TestNotifier_HostTicket(t *testing.T) {
+	nt, s := newTestNotifier(t)
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+	n.IcingaServiceName = "disk"
+
+	_, _ = nt.Process(context.Background(), n)
+
+	// Without related tickets host notifications use the open tickets of the host's services
+	n.IcingaServiceName = ""
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil || r.Created || len(s.Tickets()) != 1 {
+		t.Errorf("Expected article on the service ticket got: %s %v", r, err)
+	}
+}
+
+func TestNotifier_MergeServiceProblems(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.MergeServiceProblems = true
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+	n.IcingaServiceName = ""
+
+	_, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	n.IcingaServiceName = "disk"
+	n.IcingaCheckState = "Critical"
+
+	r, err := nt.Process(context.Background(), n)
+
+	if err != nil || r.Created || !r.ArticleAdded {
+		t.Fatalf("Expected article on the host ticket got: %s %v", r, err)
+	}
+
+	// Other notifications of services without ticket are not merged
+	n.IcingaNotificationType = "DowntimeStart"
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err != nil || r.ArticleAdded {
+		t.Fatalf("Expected downtime to be ignored got: %s %v", r, err)
+	}
+
+	// The recovery of the service does not close the host ticket
+	nt.TimeAccounting = &TimeAccounting{}
+	n.IcingaNotificationType = "Recovery"
+	n.IcingaCheckState = "OK"
+
+	defer func() { now = time.Now }()
+
+	now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err != nil || r.State != "" || !r.ArticleAdded {
+		t.Fatalf("Expected recovery article got: %s %v", r, err)
+	}
+
+	tickets := s.Tickets()
+
+	if len(tickets) != 1 || tickets[0].State() != "new" || len(s.Articles(tickets[0].ID())) != 3 {
+		t.Fatalf("Expected a single host ticket with the service articles got: %v", tickets)
+	}
+
+	if subject := s.Articles(tickets[0].ID())[2]["subject"]; subject != "Recovery disk" {
+		t.Errorf("Expected recovery article of the service got: %v", subject)
+	}
+
+	if subject := s.Articles(tickets[0].ID())[1]["subject"]; subject != "Problem disk" {
+		t.Errorf("Expected article of the service got: %v", subject)
+	}
+
+	// Without a host ticket the service gets its own ticket
+	n.IcingaNotificationType = "Problem"
+	n.IcingaHostname = "Host02"
+
+	r, err = nt.Process(context.Background(), n)
+
+	if err != nil || !r.Created {
+		t.Errorf("Expected new service ticket got: %s %v", r, err)
+	}
+}
+
+func TestNotifier_MergeServiceProblems_TimeAccounting(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.MergeServiceProblems = true
+	nt.TimeAccounting = &TimeAccounting{}
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+	n.IcingaServiceName = ""
+
+	_, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	defer func() { now = time.Now }()
+
+	now = func() time.Time { return time.Now().Add(30 * time.Minute) }
+
+	for _, service := range []string{"disk", "load"} {
+		n.IcingaServiceName = service
+
+		for _, notificationType := range []string{"Problem", "Recovery"} {
+			n.IcingaNotificationType = notificationType
+
+			_, err = nt.Process(context.Background(), n)
+
+			if err != nil {
+				t.Fatalf("Did not expect error: %v", err)
+			}
+		}
+	}
+
+	n.IcingaNotificationType = "Recovery"
+	n.IcingaServiceName = ""
+
+	_, err = nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	// The outage is only accounted once by the recovery of the host
+	var total float64
+
+	for _, a := range s.Articles(s.Tickets()[0].ID()) {
+		if v, ok := a["time_unit"].(string); ok {
+			minutes, _ := strconv.ParseFloat(v, 64)
+			total += minutes
+		}
+	}
+
+	if total != 30 {
+		t.Errorf("Expected the outage to be accounted once got: %v minutes", total)
+	}
+}
+
+func TestNotifier_MergeServiceProblems_Attributes(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.MergeServiceProblems = true
+	nt.UpdateAttributes = true
+	nt.AssignAuthor = true
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+	n.IcingaServiceName = ""
+	n.ZammadAttributes = map[string]string{"icinga_zone": "master"}
+
+	_, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	// Neither the attributes nor the owner of the host ticket are changed by its services
+	n.IcingaServiceName = "disk"
+	n.ZammadAttributes = map[string]string{"icinga_zone": "satellite"}
+
+	for _, notificationType := range []string{"Problem", "Acknowledgement"} {
+		n.IcingaNotificationType = notificationType
+		n.IcingaAuthor = "arya.stark"
+
+		r, err := nt.Process(context.Background(), n)
+
+		if err != nil || r.AttributesUpdated || r.Owner != "" {
+			t.Fatalf("Expected only an article on the host ticket got: %s %v", r, err)
+		}
+	}
+
+	ticket := s.Tickets()[0]
+
+	if ticket.String("icinga_zone") != "master" || ticket.String("owner") != "" {
+		t.Errorf("Expected unchanged host ticket got: %v", ticket)
+	}
+}
+
+func TestNotifier_ArticleOptions(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.Articles = map[string]ArticleOptions{
+		"recovery": {Type: "email", Sender: "Agent"},
+	}
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+
+	_, err := nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	n.IcingaNotificationType = "Recovery"
+
+	_, err = nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	articles := s.Articles(s.Tickets()[0].ID())
+
+	if len(articles) != 2 {
+		t.Fatalf("Expected two articles got: %v", articles)
+	}
+
+	// Problem articles use the default options
+	if articles[0]["type"] != "web" || articles[0]["internal"] != true || articles[0]["to"] != nil {
+		t.Errorf("Expected internal web article got: %v", articles[0])
+	}
+
+	// The email is sent to the customer
+	if articles[1]["type"] != "email" || articles[1]["internal"] != false || articles[1]["to"] != "jon.snow@zammad" {
+		t.Errorf("Expected public email article to the customer got: %v", articles[1])
+	}
+}
+
+func TestNotifier_ArticleRecipient(t *testing.T) {
+	nt, s := newTestNotifier(t)
+	nt.Articles = map[string]ArticleOptions{
+		"problem": {Type: "email", Sender: "Agent"},
+	}
+
+	arya := s.AddUser(zammadtest.User{"login": "arya.stark", "email": "arya.stark@zammad"})
+
+	n := testNotification()
+	n.IcingaNotificationType = "Problem"
+	n.ZammadCustomer = "id:" + strconv.Itoa(arya["id"].(int))
+
+	// Customers not given by email cannot be used as recipient without lookup
+	_, err := nt.Process(context.Background(), n)
+
+	if err == nil || !strings.Contains(err.Error(), "no recipient for the email article") || len(s.Tickets()) != 0 {
+		t.Fatalf("Expected missing recipient error got: %v", err)
+	}
+
+	nt.Recipients = resolve.New(nt.Client.(*client.Client))
+
+	_, err = nt.Process(context.Background(), n)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	articles := s.Articles(s.Tickets()[0].ID())
+
+	if len(articles) != 1 || articles[0]["to"] != "arya.stark@zammad" {
+		t.Errorf("Expected email article to the resolved customer got: %v", articles)
+	}
+}
@@ -0,0 +1,138 @@
+package resolve
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheEntry is a resolved ID stored in the cache file
+type cacheEntry struct {
+	ID      int       `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+// Cache stores resolved IDs in a JSON file until their TTL expired.
+// Since the plugin runs as a new process for each notification,
+// the file is read on the first lookup and written on each change.
+type Cache struct {
+	path string
+	ttl  time.Duration
+	// namespace separates the IDs of different Zammad instances in the same file
+	namespace string
+
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string]cacheEntry
+}
+
+// NewCache returns a cache using the given file. The namespace separates
+// the entries of different Zammad instances, e.g. by their URL.
+func NewCache(path string, ttl time.Duration, namespace string) *Cache {
+	return &Cache{
+		path:      path,
+		ttl:       ttl,
+		namespace: namespace,
+		entries:   make(map[string]cacheEntry),
+	}
+}
+
+// Get returns the cached ID for the key, if it did not expire yet
+func (c *Cache) Get(key string) (int, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+
+	e, ok := c.entries[c.namespace+" "+key]
+
+	if !ok || time.Now().After(e.Expires) {
+		return 0, false
+	}
+
+	return e.ID, true
+}
+
+// Set stores the ID for the key and writes the cache file
+func (c *Cache) Set(key string, id int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.load()
+
+	now := time.Now()
+
+	// Drop the expired entries to keep the file small
+	for k, e := range c.entries {
+		if now.After(e.Expires) {
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[c.namespace+" "+key] = cacheEntry{ID: id, Expires: now.Add(c.ttl)}
+
+	return c.write()
+}
+
+// load reads the cache file once, a missing or broken file results in an empty cache
+func (c *Cache) load() {
+	if c.loaded {
+		return
+	}
+
+	c.loaded = true
+
+	b, err := os.ReadFile(c.path)
+
+	if err != nil {
+		return
+	}
+
+	entries := make(map[string]cacheEntry)
+
+	if json.Unmarshal(b, &entries) == nil {
+		c.entries = entries
+	}
+}
+
+// write replaces the cache file, the temporary file ensures
+// other processes never read a partially written file
+func (c *Cache) write() error {
+	b, err := json.Marshal(c.entries)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(c.path), 0o700)
+
+	if err != nil {
+		return fmt.Errorf("could not create cache directory: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+
+	if err != nil {
+		return fmt.Errorf("could not write cache: %w", err)
+	}
+
+	_, err = f.Write(b)
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), c.path)
+	}
+
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("could not write cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package resolve looks up the Zammad users, groups and organizations referenced in notifications,
+// so invalid references are reported with a clear error instead of a failing ticket creation
+package resolve
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	zammad "github.com/NETWAYS/notify_zammad/internal/api"
+	"github.com/NETWAYS/notify_zammad/internal/client"
+)
+
+// ErrNotFound is returned if the referenced user, group or organization does not exist
+var ErrNotFound = errors.New("not found in Zammad")
+
+// idPrefix marks references by ID, e.g. id:42
+const idPrefix = "id:"
+
+// Resolver resolves references to Zammad users, groups and organizations to their IDs.
+// Users are referenced by email, login or id:N, groups and organizations by name or id:N.
+type Resolver struct {
+	Directory client.Directory
+	// Cache is optional, if set the resolved IDs are cached
+	Cache *Cache
+	// CreateCustomers creates the customers referenced by email that don't exist yet
+	CreateCustomers bool
+	// Logger logs the problems that do not fail the lookup
+	Logger *slog.Logger
+}
+
+// New returns a Resolver using the given directory
+func New(d client.Directory) *Resolver {
+	return &Resolver{
+		Directory: d,
+		Logger:    slog.Default(),
+	}
+}
+
+// parseID returns the ID of references like id:42
+func parseID(ref string) (int, bool, error) {
+	if !strings.HasPrefix(ref, idPrefix) {
+		return 0, false, nil
+	}
+
+	id, err := strconv.Atoi(strings.TrimPrefix(ref, idPrefix))
+
+	if err != nil || id < 1 {
+		return 0, true, fmt.Errorf("invalid reference '%s', expected id:<number>", ref)
+	}
+
+	return id, true, nil
+}
+
+// Customer returns the ID of the user referenced by email, login or id:N.
+// If CreateCustomers is set, a missing customer referenced by email is created.
+// The ID is 0 if the customer was created, but the API did not return it (e.g. in dry-run mode).
+func (r *Resolver) Customer(ctx context.Context, ref string) (int, error) {
+	return r.cached("customer:"+ref, func() (int, error) {
+		return r.lookupCustomer(ctx, ref)
+	})
+}
+
+// Email returns the email address of the customer referenced by email, login or id:N,
+// references by email are returned as they are
+func (r *Resolver) Email(ctx context.Context, ref string) (string, error) {
+	if strings.Contains(ref, "@") {
+		return ref, nil
+	}
+
+	id, err := r.Customer(ctx, ref)
+
+	if err != nil {
+		return "", err
+	}
+
+	u, err := r.Directory.GetUser(ctx, id)
+
+	if err != nil {
+		return "", err
+	}
+
+	if u.Email == "" {
+		return "", fmt.Errorf("customer '%s' has no email address", ref)
+	}
+
+	return u.Email, nil
+}
+
+// Owner returns the ID of the agent referenced by email, login or id:N
+func (r *Resolver) Owner(ctx context.Context, ref string) (int, error) {
+	return r.cached("owner:"+ref, func() (int, error) {
+		return r.lookupUser(ctx, "owner", ref)
+	})
+}
+
+// Group returns the ID of the group referenced by name or id:N
+func (r *Resolver) Group(ctx context.Context, ref string) (int, error) {
+	return r.cached("group:"+ref, func() (int, error) {
+		return r.lookupGroup(ctx, ref)
+	})
+}
+
+// Organization returns the ID of the organization referenced by name or id:N
+func (r *Resolver) Organization(ctx context.Context, ref string) (int, error) {
+	return r.cached("organization:"+ref, func() (int, error) {
+		return r.lookupOrganization(ctx, ref)
+	})
+}
+
+// cached returns the ID from the cache or looks it up and caches it
+func (r *Resolver) cached(key string, lookup func() (int, error)) (int, error) {
+	if r.Cache != nil {
+		if id, ok := r.Cache.Get(key); ok {
+			return id, nil
+		}
+	}
+
+	id, err := lookup()
+
+	if err != nil || id == 0 || r.Cache == nil {
+		return id, err
+	}
+
+	// A broken cache only costs another lookup next time
+	if err := r.Cache.Set(key, id); err != nil {
+		r.Logger.Warn("could not cache Zammad ID", "key", key, "error", err)
+	}
+
+	return id, nil
+}
+
+func (r *Resolver) lookupCustomer(ctx context.Context, ref string) (int, error) {
+	id, err := r.lookupUser(ctx, "customer", ref)
+
+	if !errors.Is(err, ErrNotFound) || !r.CreateCustomers || !strings.Contains(ref, "@") {
+		return id, err
+	}
+
+	created, err := r.Directory.CreateUser(ctx, zammad.User{
+		Login:  ref,
+		Email:  ref,
+		Active: true,
+		Roles:  []string{"Customer"},
+	})
+
+	if err != nil {
+		return 0, fmt.Errorf("customer '%s' %w and could not be created: %w", ref, ErrNotFound, err)
+	}
+
+	return created.ID, nil
+}
+
+// lookupUser returns the ID of the user referenced by email, login or id:N,
+// the kind of user is used for the error messages
+func (r *Resolver) lookupUser(ctx context.Context, kind, ref string) (int, error) {
+	id, isID, err := parseID(ref)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if isID {
+		_, err = r.Directory.GetUser(ctx, id)
+
+		if errors.Is(err, client.ErrNotFound) {
+			return 0, fmt.Errorf("%s '%s' %w", kind, ref, ErrNotFound)
+		}
+
+		return id, err
+	}
+
+	users, err := r.Directory.SearchUsers(ctx, ref)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// The search also returns partial matches
+	for _, u := range users {
+		if strings.EqualFold(u.Email, ref) || strings.EqualFold(u.Login, ref) {
+			return u.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%s '%s' %w", kind, ref, ErrNotFound)
+}
+
+func (r *Resolver) lookupGroup(ctx context.Context, ref string) (int, error) {
+	id, isID, err := parseID(ref)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if isID {
+		_, err = r.Directory.GetGroup(ctx, id)
+
+		if errors.Is(err, client.ErrNotFound) {
+			return 0, fmt.Errorf("group '%s' %w", ref, ErrNotFound)
+		}
+
+		return id, err
+	}
+
+	groups, err := r.Directory.Groups(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	for _, g := range groups {
+		if strings.EqualFold(g.Name, ref) {
+			return g.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("group '%s' %w", ref, ErrNotFound)
+}
+
+func (r *Resolver) lookupOrganization(ctx context.Context, ref string) (int, error) {
+	id, isID, err := parseID(ref)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if isID {
+		_, err = r.Directory.GetOrganization(ctx, id)
+
+		if errors.Is(err, client.ErrNotFound) {
+			return 0, fmt.Errorf("organization '%s' %w", ref, ErrNotFound)
+		}
+
+		return id, err
+	}
+
+	organizations, err := r.Directory.SearchOrganizations(ctx, ref)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// The search also returns partial matches
+	for _, o := range organizations {
+		if strings.EqualFold(o.Name, ref) {
+			return o.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("organization '%s' %w", ref, ErrNotFound)
+}
@@ -0,0 +1,254 @@
+package resolve
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NETWAYS/notify_zammad/internal/client"
+	"github.com/NETWAYS/notify_zammad/internal/zammadtest"
+)
+
+func newTestResolver(t *testing.T) (*Resolver, *zammadtest.Server) {
+	t.Helper()
+
+	s := zammadtest.NewServer()
+	t.Cleanup(s.Close)
+
+	u, _ := url.Parse(s.URL)
+
+	return New(client.NewClient(*u, http.DefaultTransport)), s
+}
+
+func TestResolver_Customer(t *testing.T) {
+	r, s := newTestResolver(t)
+
+	jon := s.AddUser(zammadtest.User{"login": "jon.snow", "email": "jon.snow@zammad"})
+	// Partial matches of the search are ignored
+	s.AddUser(zammadtest.User{"login": "jon", "email": "jon@zammad"})
+
+	testcases := map[string]string{
+		"email": "Jon.Snow@zammad",
+		"login": "jon.snow",
+		"id":    "id:" + strconv.Itoa(jon["id"].(int)),
+	}
+
+	for name, ref := range testcases {
+		t.Run(name, func(t *testing.T) {
+			id, err := r.Customer(context.Background(), ref)
+
+			if err != nil {
+				t.Fatalf("Did not expect error: %v", err)
+			}
+
+			if id != jon["id"].(int) {
+				t.Errorf("Expected ID %v got: %d", jon["id"], id)
+			}
+		})
+	}
+
+	for _, ref := range []string{"arya.stark@zammad", "id:4711"} {
+		_, err := r.Customer(context.Background(), ref)
+
+		if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "customer '"+ref+"' not found") {
+			t.Errorf("Expected not found error got: %v", err)
+		}
+	}
+
+	_, err := r.Customer(context.Background(), "id:jon")
+
+	if err == nil || !strings.Contains(err.Error(), "invalid reference") {
+		t.Errorf("Expected invalid reference error got: %v", err)
+	}
+}
+
+func TestResolver_Email(t *testing.T) {
+	r, s := newTestResolver(t)
+
+	s.AddUser(zammadtest.User{"login": "jon.snow", "email": "jon.snow@zammad"})
+	s.AddUser(zammadtest.User{"login": "arya.stark"})
+
+	email, err := r.Email(context.Background(), "jon.snow")
+
+	if err != nil || email != "jon.snow@zammad" {
+		t.Errorf("Expected email of the customer got: %s %v", email, err)
+	}
+
+	// Emails are not looked up
+	if email, _ := r.Email(context.Background(), "sansa.stark@zammad"); email != "sansa.stark@zammad" {
+		t.Errorf("Expected email as it is got: %s", email)
+	}
+
+	_, err = r.Email(context.Background(), "arya.stark")
+
+	if err == nil || !strings.Contains(err.Error(), "customer 'arya.stark' has no email address") {
+		t.Errorf("Expected missing email error got: %v", err)
+	}
+}
+
+func TestResolver_CreateCustomer(t *testing.T) {
+	r, s := newTestResolver(t)
+	r.CreateCustomers = true
+
+	id, err := r.Customer(context.Background(), "arya.stark@zammad")
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if id == 0 {
+		t.Fatal("Expected ID of the created customer")
+	}
+
+	// Only customers with an email are created
+	_, err = r.Customer(context.Background(), "arya")
+
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected not found error got: %v", err)
+	}
+
+	requests := strings.Join(s.Requests(), ",")
+
+	if strings.Count(requests, "POST /api/v1/users") != 1 {
+		t.Errorf("Expected a single created user got: %s", requests)
+	}
+}
+
+func TestResolver_Group(t *testing.T) {
+	r, s := newTestResolver(t)
+
+	users := s.AddGroup(zammadtest.Group{"name": "Users", "active": true})
+
+	for _, ref := range []string{"Users", "users", "id:" + strconv.Itoa(users["id"].(int))} {
+		id, err := r.Group(context.Background(), ref)
+
+		if err != nil || id != users["id"].(int) {
+			t.Errorf("Expected ID %v for %s got: %d %v", users["id"], ref, id, err)
+		}
+	}
+
+	for _, ref := range []string{"Admins", "id:4711"} {
+		_, err := r.Group(context.Background(), ref)
+
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected not found error for %s got: %v", ref, err)
+		}
+	}
+}
+
+func TestResolver_Cache(t *testing.T) {
+	r, s := newTestResolver(t)
+
+	s.AddGroup(zammadtest.Group{"name": "Users"})
+
+	path := filepath.Join(t.TempDir(), "cache", "ids.json")
+
+	r.Cache = NewCache(path, time.Hour, s.URL)
+
+	for range 2 {
+		_, err := r.Group(context.Background(), "Users")
+
+		if err != nil {
+			t.Fatalf("Did not expect error: %v", err)
+		}
+	}
+
+	// The cache file is used by the next process
+	r.Cache = NewCache(path, time.Hour, s.URL)
+
+	_, err := r.Group(context.Background(), "Users")
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if n := len(s.Requests()); n != 1 {
+		t.Errorf("Expected a single request got: %d", n)
+	}
+
+	// Other Zammad instances use their own IDs
+	r.Cache = NewCache(path, time.Hour, "https://zammad.example")
+
+	_, _ = r.Group(context.Background(), "Users")
+
+	if n := len(s.Requests()); n != 2 {
+		t.Errorf("Expected a request for the other instance got: %d", n)
+	}
+}
+
+func TestResolver_BrokenCache(t *testing.T) {
+	r, s := newTestResolver(t)
+
+	s.AddGroup(zammadtest.Group{"name": "Users"})
+
+	// The cache directory cannot be created below a file
+	file := filepath.Join(t.TempDir(), "file")
+
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+
+	r.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	r.Cache = NewCache(filepath.Join(file, "ids.json"), time.Hour, s.URL)
+
+	_, err := r.Group(context.Background(), "Users")
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if !strings.Contains(logs.String(), "could not cache Zammad ID") {
+		t.Errorf("Expected warning in the log of the resolver got: %s", logs.String())
+	}
+}
+
+func TestCache_Expired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ids.json")
+
+	c := NewCache(path, -time.Second, "https://zammad.example")
+
+	err := c.Set("group:Users", 1)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if _, ok := c.Get("group:Users"); ok {
+		t.Error("Expected expired entry to be ignored")
+	}
+}
+
+func TestResolver_Organization(t *testing.T) {
+	r, s := newTestResolver(t)
+
+	acme := s.AddOrganization(zammadtest.Organization{"name": "ACME"})
+	// Partial matches of the search are ignored
+	s.AddOrganization(zammadtest.Organization{"name": "ACME Europe"})
+
+	for _, ref := range []string{"ACME", "acme", "id:" + strconv.Itoa(acme["id"].(int))} {
+		id, err := r.Organization(context.Background(), ref)
+
+		if err != nil || id != acme["id"].(int) {
+			t.Errorf("Expected ID %v for %s got: %d %v", acme["id"], ref, id, err)
+		}
+	}
+
+	for _, ref := range []string{"ACM", "id:4711"} {
+		_, err := r.Organization(context.Background(), ref)
+
+		if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), "organization '"+ref+"' not found") {
+			t.Errorf("Expected not found error for %s got: %v", ref, err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Package routing selects the Zammad fields of new tickets by rules
+// matching the monitored object, e.g. to choose the group per team
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// DefaultRule is the name reported if no rule matched
+const DefaultRule = "default"
+
+// Object is the monitored object a notification is about
+type Object struct {
+	Host       string
+	Service    string
+	HostGroups []string
+	Vars       map[string]string
+}
+
+// Route holds the Zammad fields chosen for a ticket, empty fields are not set
+type Route struct {
+	Group        string   `json:"group,omitempty"`
+	Customer     string   `json:"customer,omitempty"`
+	Organization string   `json:"organization,omitempty"`
+	Owner        string   `json:"owner,omitempty"`
+	Priority     string   `json:"priority,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+	// Attributes are additional object manager attributes of the ticket
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Match holds the conditions of a rule, all conditions that are set have to match.
+// A pattern is a glob like web-* or a regular expression enclosed in slashes like /^web-\d+$/.
+type Match struct {
+	Host    *Pattern `json:"host,omitempty"`
+	Service *Pattern `json:"service,omitempty"`
+	// HostGroup matches if any of the host groups matches
+	HostGroup *Pattern `json:"host_group,omitempty"`
+	// Vars match the custom variables by their name
+	Vars map[string]*Pattern `json:"vars,omitempty"`
+}
+
+// Rule routes the matching objects
+type Rule struct {
+	Name  string `json:"name"`
+	Match Match  `json:"match"`
+	Route
+}
+
+// Rules are evaluated in order, the first matching rule wins.
+// The default route is used for the fields not set by the rule.
+type Rules struct {
+	Rules   []Rule `json:"rules"`
+	Default Route  `json:"default"`
+}
+
+// Load reads the rules from the given JSON file
+func Load(path string) (*Rules, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open routing rules: %w", err)
+	}
+
+	defer f.Close()
+
+	rules, err := Parse(f)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not parse routing rules %s: %w", path, err)
+	}
+
+	return rules, nil
+}
+
+// Parse reads the rules as JSON from the given reader
+func Parse(r io.Reader) (*Rules, error) {
+	var rules Rules
+
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(&rules)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rules.Rules {
+		if rules.Rules[i].Name == "" {
+			rules.Rules[i].Name = fmt.Sprintf("rule %d", i+1)
+		}
+	}
+
+	return &rules, nil
+}
+
+// Route returns the route of the first rule matching the object and the name of the rule.
+// Fields the rule does not set are taken from the default route,
+// which is returned if no rule matches. The rules may be nil.
+func (r *Rules) Route(o Object) (Route, string) {
+	if r == nil {
+		return Route{}, DefaultRule
+	}
+
+	for _, rule := range r.Rules {
+		if rule.Match.matches(o) {
+			return rule.Route.withDefault(r.Default), rule.Name
+		}
+	}
+
+	return r.Default, DefaultRule
+}
+
+// withDefault returns the route with the empty fields set from the default route
+func (r Route) withDefault(d Route) Route {
+	fields := []struct {
+		value *string
+		def   string
+	}{
+		{&r.Group, d.Group},
+		{&r.Customer, d.Customer},
+		{&r.Organization, d.Organization},
+		{&r.Owner, d.Owner},
+		{&r.Priority, d.Priority},
+	}
+
+	for _, f := range fields {
+		if *f.value == "" {
+			*f.value = f.def
+		}
+	}
+
+	if len(r.Tags) == 0 {
+		r.Tags = d.Tags
+	}
+
+	// The attributes of the rule are added to the default attributes
+	if len(d.Attributes) > 0 {
+		attributes := make(map[string]string, len(d.Attributes)+len(r.Attributes))
+
+		for k, v := range d.Attributes {
+			attributes[k] = v
+		}
+
+		for k, v := range r.Attributes {
+			attributes[k] = v
+		}
+
+		r.Attributes = attributes
+	}
+
+	return r
+}
+
+func (m Match) matches(o Object) bool {
+	if m.Host != nil && !m.Host.MatchString(o.Host) {
+		return false
+	}
+
+	if m.Service != nil && !m.Service.MatchString(o.Service) {
+		return false
+	}
+
+	if m.HostGroup != nil && !m.HostGroup.matchesAny(o.HostGroups) {
+		return false
+	}
+
+	for name, p := range m.Vars {
+		value, ok := o.Vars[name]
+
+		if !ok || !p.MatchString(value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Pattern is a glob or a regular expression enclosed in slashes
+type Pattern struct {
+	raw string
+	re  *regexp.Regexp
+}
+
+// ParsePattern parses a glob like web-* or a regular expression like /^web-\d+$/.
+// Globs match the whole value, * matches any number of characters and ? a single character.
+func ParsePattern(s string) (*Pattern, error) {
+	if len(s) > 1 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		re, err := regexp.Compile(s[1 : len(s)-1])
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", s, err)
+		}
+
+		return &Pattern{raw: s, re: re}, nil
+	}
+
+	glob := regexp.QuoteMeta(s)
+	glob = strings.ReplaceAll(glob, `\*`, ".*")
+	glob = strings.ReplaceAll(glob, `\?`, ".")
+
+	return &Pattern{raw: s, re: regexp.MustCompile("^" + glob + "$")}, nil
+}
+
+// MatchString reports if the value matches the pattern
+func (p *Pattern) MatchString(s string) bool {
+	return p.re.MatchString(s)
+}
+
+func (p *Pattern) matchesAny(values []string) bool {
+	for _, v := range values {
+		if p.MatchString(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// String returns the pattern as written in the rules
+func (p *Pattern) String() string {
+	return p.raw
+}
+
+// UnmarshalJSON parses the pattern from a JSON string
+func (p *Pattern) UnmarshalJSON(b []byte) error {
+	var s string
+
+	err := json.Unmarshal(b, &s)
+
+	if err != nil {
+		return err
+	}
+
+	parsed, err := ParsePattern(s)
+
+	if err != nil {
+		return err
+	}
+
+	*p = *parsed
+
+	return nil
+}
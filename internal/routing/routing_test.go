@@ -0,0 +1,165 @@
+package routing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRules = `{
+  "rules": [
+    {
+      "name": "databases",
+      "match": {"host": "db-*", "vars": {"env": "/^prod(uction)?$/"}},
+      "group": "DBA",
+      "customer": "dba@example.com",
+      "priority": "3 high",
+      "tags": ["database"],
+      "attributes": {"service_owner": "dba"}
+    },
+    {
+      "name": "web",
+      "match": {"host_group": "web*", "service": "http?"},
+      "group": "Web",
+      "owner": "jon.snow"
+    },
+    {
+      "match": {"service": "/^backup/"},
+      "group": "Backup"
+    }
+  ],
+  "default": {"group": "Users", "customer": "monitoring@example.com", "attributes": {"environment": "production", "service_owner": "ops"}}
+}`
+
+func TestRules_Route(t *testing.T) {
+	rules, err := Parse(strings.NewReader(testRules))
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	testcases := map[string]struct {
+		object Object
+		rule   string
+		group  string
+	}{
+		"host-and-var": {
+			object: Object{Host: "db-01", Vars: map[string]string{"env": "production"}},
+			rule:   "databases",
+			group:  "DBA",
+		},
+		"var-mismatch": {
+			object: Object{Host: "db-01", Vars: map[string]string{"env": "staging"}},
+			rule:   DefaultRule,
+			group:  "Users",
+		},
+		"var-missing": {
+			object: Object{Host: "db-01"},
+			rule:   DefaultRule,
+			group:  "Users",
+		},
+		"host-group": {
+			object: Object{Host: "srv-01", Service: "https", HostGroups: []string{"linux", "webservers"}},
+			rule:   "web",
+			group:  "Web",
+		},
+		"glob-is-anchored": {
+			object: Object{Host: "srv-01", Service: "https-cert", HostGroups: []string{"webservers"}},
+			rule:   DefaultRule,
+			group:  "Users",
+		},
+		"unnamed-rule": {
+			object: Object{Host: "srv-01", Service: "backup-daily"},
+			rule:   "rule 3",
+			group:  "Backup",
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			route, rule := rules.Route(tc.object)
+
+			if rule != tc.rule || route.Group != tc.group {
+				t.Errorf("Expected rule %s with group %s got: %s %v", tc.rule, tc.group, rule, route)
+			}
+
+			if route.Customer == "" {
+				t.Errorf("Expected customer from the rule or the default got: %v", route)
+			}
+		})
+	}
+}
+
+func TestRules_Attributes(t *testing.T) {
+	rules, err := Parse(strings.NewReader(testRules))
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	route, _ := rules.Route(Object{Host: "db-01", Vars: map[string]string{"env": "production"}})
+
+	if route.Attributes["service_owner"] != "dba" || route.Attributes["environment"] != "production" {
+		t.Errorf("Expected attributes of the rule and the default got: %v", route.Attributes)
+	}
+
+	// The default attributes are not changed by the rule
+	if rules.Default.Attributes["service_owner"] != "ops" {
+		t.Errorf("Expected unchanged default attributes got: %v", rules.Default.Attributes)
+	}
+}
+
+func TestRules_Nil(t *testing.T) {
+	var rules *Rules
+
+	route, rule := rules.Route(Object{Host: "db-01"})
+
+	if rule != DefaultRule || route.Group != "" {
+		t.Errorf("Expected empty default route got: %s %v", rule, route)
+	}
+}
+
+func TestParse_Error(t *testing.T) {
+	testcases := map[string]string{
+		"invalid-regex": `{"rules": [{"match": {"host": "/db-(/"}}]}`,
+		"unknown-field": `{"rules": [{"match": {"hostname": "db-*"}}]}`,
+		"invalid-json":  `{"rules": [`,
+	}
+
+	for name, rules := range testcases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(rules))
+
+			if err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "routing.json")
+
+	err := os.WriteFile(path, []byte(testRules), 0o600)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := Load(path)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	if len(rules.Rules) != 3 || rules.Default.Customer != "monitoring@example.com" {
+		t.Errorf("Expected rules from file got: %v", rules)
+	}
+
+	_, err = Load(filepath.Join(t.TempDir(), "missing.json"))
+
+	if err == nil || !strings.Contains(err.Error(), "could not open routing rules") {
+		t.Errorf("Expected error for missing file got: %v", err)
+	}
+}
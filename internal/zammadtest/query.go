@@ -0,0 +1,84 @@
+package zammadtest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// term matches a single attribute of a ticket, e.g. "icinga_host: web01"
+type term struct {
+	field string
+	value string
+}
+
+// match reports if the ticket attribute matches the term.
+// A value of * matches any non-empty attribute.
+func (t term) match(ticket Ticket) bool {
+	field := t.field
+
+	// Zammad allows to search for the state name via the relation
+	if field == "state.name" {
+		field = "state"
+	}
+
+	value := ticket.String(field)
+
+	if t.value == "*" {
+		return value != ""
+	}
+
+	return strings.EqualFold(value, t.value)
+}
+
+// query is a conjunction of disjunctions of terms, which covers the
+// subset of the Zammad search syntax used by notify_zammad, e.g.
+// "icinga_host: web01 AND (state.name: new OR state.name: open)"
+type query [][]term
+
+func (q query) match(ticket Ticket) bool {
+	for _, clause := range q {
+		matched := false
+
+		for _, t := range clause {
+			if t.match(ticket) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseQuery parses a search query into a query
+func parseQuery(s string) (query, error) {
+	q := make(query, 0)
+
+	for _, clause := range strings.Split(s, " AND ") {
+		clause = strings.TrimSpace(clause)
+		clause = strings.TrimSuffix(strings.TrimPrefix(clause, "("), ")")
+
+		terms := make([]term, 0)
+
+		for _, part := range strings.Split(clause, " OR ") {
+			field, value, ok := strings.Cut(part, ":")
+
+			if !ok {
+				return nil, fmt.Errorf("unsupported query term '%s'", part)
+			}
+
+			terms = append(terms, term{
+				field: strings.TrimSpace(field),
+				value: strings.TrimSpace(value),
+			})
+		}
+
+		q = append(q, terms)
+	}
+
+	return q, nil
+}
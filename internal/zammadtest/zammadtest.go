@@ -0,0 +1,736 @@
+// Package zammadtest provides an in-memory fake of the Zammad API for tests.
+//
+// The fake implements the parts of the API used by notify_zammad: tickets,
+// articles, ticket states, the ticket search with the icinga_host/icinga_service
+// query semantics, tags, links, users, groups and organizations. Tickets and users are stored as plain JSON
+// objects, thus custom object attributes are kept as they are sent.
+package zammadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ticket represents a ticket stored by the fake
+type Ticket map[string]any
+
+// ID returns the ID of the ticket
+func (t Ticket) ID() int {
+	return toInt(t["id"])
+}
+
+// State returns the name of the ticket state
+func (t Ticket) State() string {
+	return t.String("state")
+}
+
+// String returns the given attribute as string
+func (t Ticket) String(key string) string {
+	if v, ok := t[key]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+
+	return ""
+}
+
+// Article represents an article stored by the fake
+type Article map[string]any
+
+// Link represents a link between two tickets stored by the fake
+type Link struct {
+	Type     string
+	SourceID int
+	TargetID int
+}
+
+// User represents a user stored by the fake
+type User map[string]any
+
+// Group represents a group stored by the fake
+type Group map[string]any
+
+// Organization represents an organization stored by the fake
+type Organization map[string]any
+
+// ticketStates are the default ticket states of Zammad
+var ticketStates = map[string]int{
+	"new":    1,
+	"open":   2,
+	"closed": 4,
+}
+
+// Server is a fake Zammad API served via httptest
+type Server struct {
+	*httptest.Server
+
+	// Token is required for all requests if set, either as
+	// "Token <token>" or "Token token=<token>" Authorization header
+	Token string
+
+	mu       sync.Mutex
+	tickets  map[int]Ticket
+	articles map[int][]Article
+	tags     map[int][]string
+	links    []Link
+	users    map[int]User
+	groups   map[int]Group
+	orgs     map[int]Organization
+	lastID   int
+	requests []string
+}
+
+// NewServer starts a new fake Zammad API.
+// The server needs to be closed by the caller.
+func NewServer() *Server {
+	s := &Server{
+		tickets:  make(map[int]Ticket),
+		articles: make(map[int][]Article),
+		tags:     make(map[int][]string),
+		users:    make(map[int]User),
+		groups:   make(map[int]Group),
+		orgs:     make(map[int]Organization),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/tickets/search", s.searchTickets)
+	mux.HandleFunc("POST /api/v1/tickets", s.createTicket)
+	mux.HandleFunc("GET /api/v1/tickets/{id}", s.getTicket)
+	mux.HandleFunc("PUT /api/v1/tickets/{id}", s.updateTicket)
+	mux.HandleFunc("POST /api/v1/ticket_articles", s.createArticle)
+	mux.HandleFunc("GET /api/v1/ticket_articles/by_ticket/{id}", s.listArticles)
+	mux.HandleFunc("GET /api/v1/tags", s.listTags)
+	mux.HandleFunc("POST /api/v1/tags/add", s.addTag)
+	mux.HandleFunc("POST /api/v1/links/add", s.addLink)
+	mux.HandleFunc("GET /api/v1/users/search", s.searchUsers)
+	mux.HandleFunc("GET /api/v1/users/{id}", s.getUser)
+	mux.HandleFunc("POST /api/v1/users", s.createUser)
+	mux.HandleFunc("GET /api/v1/groups", s.listGroups)
+	mux.HandleFunc("GET /api/v1/groups/{id}", s.getGroup)
+	mux.HandleFunc("GET /api/v1/organizations/search", s.searchOrganizations)
+	mux.HandleFunc("GET /api/v1/organizations/{id}", s.getOrganization)
+
+	s.Server = httptest.NewServer(s.authenticate(mux))
+
+	return s
+}
+
+// AddTicket stores the given ticket and returns it with its ID, number and state set.
+// Missing states default to new.
+func (s *Server) AddTicket(t Ticket) Ticket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return clone(s.addTicket(t))
+}
+
+// AddGroup stores the given group and returns it with its ID set
+func (s *Server) AddGroup(g Group) Group {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	g["id"] = s.lastID
+	s.groups[s.lastID] = g
+
+	return g
+}
+
+// AddOrganization stores the given organization and returns it with its ID set
+func (s *Server) AddOrganization(o Organization) Organization {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	o["id"] = s.lastID
+	s.orgs[s.lastID] = o
+
+	return o
+}
+
+// AddUser stores the given user and returns it with its ID set
+func (s *Server) AddUser(u User) User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastID++
+	u["id"] = s.lastID
+	s.users[s.lastID] = u
+
+	return u
+}
+
+// Tickets returns all stored tickets ordered by their ID
+func (s *Server) Tickets() []Ticket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tickets := make([]Ticket, 0, len(s.tickets))
+
+	// The tickets are copied, since they are changed by requests running concurrently
+	for _, t := range s.tickets {
+		tickets = append(tickets, clone(t))
+	}
+
+	sort.Slice(tickets, func(i, j int) bool {
+		return tickets[i].ID() < tickets[j].ID()
+	})
+
+	return tickets
+}
+
+// Articles returns all articles of the given ticket
+func (s *Server) Articles(ticketID int) []Article {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	articles := make([]Article, 0, len(s.articles[ticketID]))
+
+	for _, a := range s.articles[ticketID] {
+		articles = append(articles, clone(a))
+	}
+
+	return articles
+}
+
+// Tags returns all tags of the given ticket
+func (s *Server) Tags(ticketID int) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.tags[ticketID]...)
+}
+
+// Links returns all links between tickets
+func (s *Server) Links() []Link {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Link{}, s.links...)
+}
+
+// Requests returns method and path of all requests received by the server
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.Method+" "+r.URL.Path)
+		s.mu.Unlock()
+
+		if s.Token != "" {
+			auth := r.Header.Get("Authorization")
+
+			if auth != "Token "+s.Token && auth != "Token token="+s.Token {
+				writeError(w, http.StatusUnauthorized, "authentication failed")
+				return
+			}
+		}
+
+		// Requests on behalf of another user are performed as this user
+		if login := r.Header.Get("X-On-Behalf-Of"); login != "" {
+			s.mu.Lock()
+			u, ok := s.findUser(login)
+			s.mu.Unlock()
+
+			if !ok {
+				writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("No such user '%s'", login))
+				return
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), actorKey{}, u))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// actorKey references the user a request is performed as
+type actorKey struct{}
+
+// actor returns the login of the user the request is performed as,
+// which is empty for requests as the API user
+func actor(r *http.Request) string {
+	if u, ok := r.Context().Value(actorKey{}).(User); ok {
+		return fmt.Sprint(u["login"])
+	}
+
+	return ""
+}
+
+// findUser looks up a user by login, email or ID and expects the lock to be held
+func (s *Server) findUser(ref string) (User, bool) {
+	for id, u := range s.users {
+		if strconv.Itoa(id) == ref || u["login"] == ref || u["email"] == ref {
+			return u, true
+		}
+	}
+
+	return nil, false
+}
+
+// addTicket expects the lock to be held
+func (s *Server) addTicket(t Ticket) Ticket {
+	s.lastID++
+
+	now := time.Now().UTC().Format(time.RFC3339Nano)
+
+	t["id"] = s.lastID
+	t["number"] = strconv.Itoa(65000 + s.lastID)
+	t["created_at"] = now
+	t["updated_at"] = now
+
+	if t.State() == "" {
+		t["state"] = "new"
+	}
+
+	t["state_id"] = ticketStates[t.State()]
+
+	s.tickets[s.lastID] = t
+
+	return t
+}
+
+// addArticle expects the lock to be held
+func (s *Server) addArticle(ticketID int, a Article) Article {
+	s.lastID++
+
+	a["id"] = s.lastID
+	a["ticket_id"] = ticketID
+	a["created_at"] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	s.articles[ticketID] = append(s.articles[ticketID], a)
+
+	return a
+}
+
+func (s *Server) searchTickets(w http.ResponseWriter, r *http.Request) {
+	q, err := parseQuery(r.URL.Query().Get("query"))
+
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	result := make([]Ticket, 0)
+
+	for _, t := range s.Tickets() {
+		if q.match(t) {
+			result = append(result, t)
+		}
+	}
+
+	// The plugin requests the newest tickets first
+	if r.URL.Query().Get("order_by") == "desc" {
+		sort.Slice(result, func(i, j int) bool {
+			return result[i].ID() > result[j].ID()
+		})
+	}
+
+	if page := toInt(r.URL.Query().Get("page")); page > 0 {
+		perPage := toInt(r.URL.Query().Get("per_page"))
+		start := min((page-1)*perPage, len(result))
+		end := min(start+perPage, len(result))
+		result = result[start:end]
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) createTicket(w http.ResponseWriter, r *http.Request) {
+	var t Ticket
+
+	if !decode(w, r, &t) {
+		return
+	}
+
+	if t.String("title") == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Need title for ticket creation")
+		return
+	}
+
+	if t.String("group") == "" && t.String("group_id") == "" {
+		writeError(w, http.StatusUnprocessableEntity, "Need group for ticket creation")
+		return
+	}
+
+	article, hasArticle := t["article"].(map[string]any)
+	delete(t, "article")
+
+	s.mu.Lock()
+
+	t = s.addTicket(t)
+
+	if hasArticle {
+		s.addArticle(t.ID(), article)
+	}
+
+	t = clone(t)
+
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusCreated, t)
+}
+
+func (s *Server) getTicket(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	t, ok := s.tickets[toInt(r.PathValue("id"))]
+	t = clone(t)
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Couldn't find Ticket")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *Server) updateTicket(w http.ResponseWriter, r *http.Request) {
+	var update Ticket
+
+	if !decode(w, r, &update) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.tickets[toInt(r.PathValue("id"))]
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Couldn't find Ticket")
+		return
+	}
+
+	if state := update.State(); state != "" {
+		if _, ok := ticketStates[state]; !ok {
+			writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("No lookup value found for 'state': %q", state))
+			return
+		}
+
+		update["state_id"] = ticketStates[state]
+	}
+
+	for k, v := range update {
+		if k != "id" && k != "number" {
+			t[k] = v
+		}
+	}
+
+	t["updated_at"] = time.Now().UTC().Format(time.RFC3339Nano)
+
+	if login := actor(r); login != "" {
+		t["updated_by"] = login
+	}
+
+	writeJSON(w, http.StatusOK, t)
+}
+
+func (s *Server) createArticle(w http.ResponseWriter, r *http.Request) {
+	var a Article
+
+	if !decode(w, r, &a) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ticketID := toInt(a["ticket_id"])
+
+	if _, ok := s.tickets[ticketID]; !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Need ticket_id for article creation")
+		return
+	}
+
+	if login := actor(r); login != "" {
+		a["created_by"] = login
+	}
+
+	writeJSON(w, http.StatusCreated, s.addArticle(ticketID, a))
+}
+
+func (s *Server) listArticles(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.Articles(toInt(r.PathValue("id"))))
+}
+
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string][]string{
+		"tags": s.Tags(toInt(r.URL.Query().Get("o_id"))),
+	})
+}
+
+func (s *Server) addTag(w http.ResponseWriter, r *http.Request) {
+	var tag struct {
+		Object   string `json:"object"`
+		ObjectID any    `json:"o_id"`
+		Item     string `json:"item"`
+	}
+
+	if !decode(w, r, &tag) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ticketID := toInt(tag.ObjectID)
+
+	if _, ok := s.tickets[ticketID]; tag.Object != "Ticket" || !ok {
+		writeError(w, http.StatusUnprocessableEntity, "Couldn't find object")
+		return
+	}
+
+	for _, t := range s.tags[ticketID] {
+		if t == tag.Item {
+			writeJSON(w, http.StatusCreated, true)
+			return
+		}
+	}
+
+	s.tags[ticketID] = append(s.tags[ticketID], tag.Item)
+
+	writeJSON(w, http.StatusCreated, true)
+}
+
+func (s *Server) addLink(w http.ResponseWriter, r *http.Request) {
+	var link struct {
+		LinkType     string `json:"link_type"`
+		Source       string `json:"link_object_source"`
+		SourceNumber string `json:"link_object_source_number"`
+		Target       string `json:"link_object_target"`
+		TargetValue  any    `json:"link_object_target_value"`
+	}
+
+	if !decode(w, r, &link) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l := Link{Type: link.LinkType, TargetID: toInt(link.TargetValue)}
+
+	// The source is referenced by its number
+	for _, t := range s.tickets {
+		if t.String("number") == link.SourceNumber {
+			l.SourceID = t.ID()
+		}
+	}
+
+	if _, ok := s.tickets[l.TargetID]; !ok || l.SourceID == 0 || link.Source != "Ticket" || link.Target != "Ticket" {
+		writeError(w, http.StatusUnprocessableEntity, "No such ticket")
+		return
+	}
+
+	switch l.Type {
+	case "normal", "parent", "child":
+	default:
+		writeError(w, http.StatusUnprocessableEntity, fmt.Sprintf("Invalid link_type %q", l.Type))
+		return
+	}
+
+	s.links = append(s.links, l)
+
+	writeJSON(w, http.StatusCreated, map[string]any{
+		"link_type": l.Type,
+		"source_id": l.SourceID,
+		"target_id": l.TargetID,
+	})
+}
+
+func (s *Server) searchUsers(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.URL.Query().Get("query"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]User, 0)
+
+	for _, u := range s.users {
+		for _, field := range []string{"login", "email", "firstname", "lastname"} {
+			if v, ok := u[field].(string); ok && strings.Contains(strings.ToLower(v), query) {
+				result = append(result, u)
+				break
+			}
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return toInt(result[i]["id"]) < toInt(result[j]["id"])
+	})
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	u, ok := s.users[toInt(r.PathValue("id"))]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Couldn't find User")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *Server) createUser(w http.ResponseWriter, r *http.Request) {
+	var u User
+
+	if !decode(w, r, &u) {
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, s.AddUser(u))
+}
+
+func (s *Server) listGroups(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Group, 0, len(s.groups))
+
+	for _, g := range s.groups {
+		result = append(result, g)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return toInt(result[i]["id"]) < toInt(result[j]["id"])
+	})
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getGroup(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	g, ok := s.groups[toInt(r.PathValue("id"))]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Couldn't find Group")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, g)
+}
+
+func (s *Server) searchOrganizations(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.URL.Query().Get("query"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]Organization, 0)
+
+	for _, o := range s.orgs {
+		if name, ok := o["name"].(string); ok && strings.Contains(strings.ToLower(name), query) {
+			result = append(result, o)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return toInt(result[i]["id"]) < toInt(result[j]["id"])
+	})
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) getOrganization(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	o, ok := s.orgs[toInt(r.PathValue("id"))]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Couldn't find Organization")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, o)
+}
+
+// clone returns a deep copy of the JSON object, so it can be used after the lock is released
+func clone[M ~map[string]any](m M) M {
+	if m == nil {
+		return nil
+	}
+
+	return cloneValue(map[string]any(m)).(map[string]any)
+}
+
+func cloneValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		c := make(map[string]any, len(v))
+
+		for k, e := range v {
+			c[k] = cloneValue(e)
+		}
+
+		return c
+	case []any:
+		c := make([]any, len(v))
+
+		for i, e := range v {
+			c[i] = cloneValue(e)
+		}
+
+		return c
+	default:
+		return v
+	}
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{
+		"error":       msg,
+		"error_human": msg,
+	})
+}
+
+// toInt converts JSON numbers and strings to an int, invalid values return 0
+func toInt(v any) int {
+	switch i := v.(type) {
+	case int:
+		return i
+	case float64:
+		return int(i)
+	case string:
+		n, _ := strconv.Atoi(i)
+		return n
+	default:
+		return 0
+	}
+}
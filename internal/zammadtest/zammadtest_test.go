@@ -0,0 +1,191 @@
+package zammadtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func request(t *testing.T, s *Server, method, path, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Token "+s.Token)
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func TestServer_Tickets(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	resp := request(t, s, http.MethodPost, "/api/v1/tickets",
+		`{"title": "foo", "group": "Users", "icinga_host": "web01", "article": {"body": "bar"}}`)
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("Expected 201 got: %d", resp.StatusCode)
+	}
+
+	var ticket Ticket
+	_ = json.NewDecoder(resp.Body).Decode(&ticket)
+
+	if ticket.ID() == 0 || ticket.String("number") == "" || ticket.State() != "new" {
+		t.Errorf("Expected ticket with ID, number and state got: %v", ticket)
+	}
+
+	if len(s.Articles(ticket.ID())) != 1 {
+		t.Errorf("Expected article got: %v", s.Articles(ticket.ID()))
+	}
+
+	resp = request(t, s, http.MethodPut, "/api/v1/tickets/"+ticket.String("id"), `{"state": "closed"}`)
+
+	if resp.StatusCode != http.StatusOK || s.Tickets()[0].State() != "closed" {
+		t.Errorf("Expected closed ticket got: %d %v", resp.StatusCode, s.Tickets())
+	}
+
+	resp = request(t, s, http.MethodPut, "/api/v1/tickets/"+ticket.String("id"), `{"state": "foo"}`)
+
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for unknown state got: %d", resp.StatusCode)
+	}
+
+	// The returned tickets are copies
+	s.Tickets()[0]["state"] = "new"
+
+	if s.Tickets()[0].State() != "closed" {
+		t.Errorf("Expected unchanged ticket got: %v", s.Tickets())
+	}
+
+	resp = request(t, s, http.MethodPost, "/api/v1/tickets", `{"title": "foo"}`)
+
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for missing group got: %d", resp.StatusCode)
+	}
+}
+
+func TestServer_Search(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.AddTicket(Ticket{"title": "1", "icinga_host": "web01"})
+	s.AddTicket(Ticket{"title": "2", "icinga_host": "web01", "icinga_service": "http", "state": "open"})
+	s.AddTicket(Ticket{"title": "3", "icinga_host": "web01", "state": "closed"})
+	s.AddTicket(Ticket{"title": "4", "icinga_host": "db01"})
+	s.AddTicket(Ticket{"title": "5"})
+
+	testcases := map[string]struct {
+		query    string
+		expected []string
+	}{
+		"host": {
+			query:    "icinga_host: web01 AND (state.name: new OR state.name: open)",
+			expected: []string{"2", "1"},
+		},
+		"service": {
+			query:    "icinga_host: web01 AND icinga_service: http",
+			expected: []string{"2"},
+		},
+		"wildcard": {
+			query:    "icinga_host: * AND (state.name: new OR state.name: open)",
+			expected: []string{"4", "2", "1"},
+		},
+	}
+
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			params := url.Values{"query": {tc.query}, "order_by": {"desc"}}
+			resp := request(t, s, http.MethodGet, "/api/v1/tickets/search?"+params.Encode(), "")
+
+			var tickets []Ticket
+			_ = json.NewDecoder(resp.Body).Decode(&tickets)
+
+			titles := make([]string, 0, len(tickets))
+			for _, ticket := range tickets {
+				titles = append(titles, ticket.String("title"))
+			}
+
+			if strings.Join(titles, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("\nActual: %v\nExpected: %v", titles, tc.expected)
+			}
+		})
+	}
+
+	params := url.Values{"query": {"icinga_host: *"}, "page": {"2"}, "per_page": {"2"}}
+	resp := request(t, s, http.MethodGet, "/api/v1/tickets/search?"+params.Encode(), "")
+
+	var tickets []Ticket
+	_ = json.NewDecoder(resp.Body).Decode(&tickets)
+
+	if len(tickets) != 2 || tickets[0].String("title") != "3" {
+		t.Errorf("Expected second page got: %v", tickets)
+	}
+}
+
+func TestServer_TagsAndUsers(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	ticket := s.AddTicket(Ticket{"title": "foo"})
+	s.AddUser(User{"login": "jdoe", "email": "jdoe@example.com"})
+
+	for range 2 {
+		resp := request(t, s, http.MethodPost, "/api/v1/tags/add",
+			`{"object": "Ticket", "o_id": `+ticket.String("id")+`, "item": "icinga"}`)
+
+		if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("Expected 201 got: %d", resp.StatusCode)
+		}
+	}
+
+	if tags := s.Tags(ticket.ID()); len(tags) != 1 || tags[0] != "icinga" {
+		t.Errorf("Expected single tag got: %v", tags)
+	}
+
+	resp := request(t, s, http.MethodGet, "/api/v1/users/search?query=jdoe%40example.com", "")
+
+	var users []User
+	_ = json.NewDecoder(resp.Body).Decode(&users)
+
+	if len(users) != 1 || users[0]["login"] != "jdoe" {
+		t.Errorf("Expected user got: %v", users)
+	}
+}
+
+func TestServer_Token(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.Token = "secret"
+
+	resp, err := http.Get(s.URL + "/api/v1/tickets/1")
+
+	if err != nil {
+		t.Fatalf("Did not expect error: %v", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 got: %d", resp.StatusCode)
+	}
+
+	if resp := request(t, s, http.MethodGet, "/api/v1/tickets/1", ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 got: %d", resp.StatusCode)
+	}
+}